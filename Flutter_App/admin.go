@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// adminShutdown starts the same graceful shutdown as SIGTERM. The 202 is
+// written before the drain reaches this request, since Shutdown waits for
+// in-flight requests to finish.
+func (s *server) adminShutdown(w http.ResponseWriter, r *http.Request) {
+	if !s.requestShutdown() {
+		writeJSONError(w, http.StatusConflict, "Server is already shutting down")
+		return
+	}
+	slog.WarnContext(r.Context(), "shutdown requested", "remote_addr", r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "shutting down"})
+}
+
+// getLogLevel reports the current log level.
+func (s *server) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, logLevelBody{Level: strings.ToLower(s.logLevel.Level().String())})
+}
+
+// setLogLevel changes the log level for every subsequent log call, without a
+// restart.
+func (s *server) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	if err := decodeJSONBody(w, r, &body, s.cfg.MaxBodyBytes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if body.Level == "" {
+		writeJSONError(w, http.StatusBadRequest, "level must be info or debug")
+		return
+	}
+	level, err := parseLogLevel(body.Level)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	previous := s.logLevel.Level()
+	s.logLevel.Set(level)
+	slog.InfoContext(r.Context(), "log level changed",
+		"from", strings.ToLower(previous.String()),
+		"to", strings.ToLower(level.String()),
+	)
+	writeJSON(w, http.StatusOK, logLevelBody{Level: strings.ToLower(level.String())})
+}
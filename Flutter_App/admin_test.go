@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	cfg := testConfig()
+	cfg.AuthTokens = []string{"s3cret"}
+	s := newTestServer(t, cfg)
+	var logs bytes.Buffer
+	h := s.handler(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: s.logLevel})))
+	auth := []string{"Authorization", "Bearer s3cret"}
+
+	// requestLog returns the request log line for the last POST /echo.
+	requestLog := func() map[string]any {
+		t.Helper()
+		logs.Reset()
+		do(t, h, "POST", "/echo", `{"text":"log me"}`, auth...)
+		var entry map[string]any
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("decoding log %q: %v", logs.String(), err)
+		}
+		return entry
+	}
+
+	if _, ok := requestLog()["body"]; ok {
+		t.Error("request body logged at info level")
+	}
+
+	if rec := do(t, h, "POST", "/admin/loglevel", `{"level":"debug"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("changing the level without a token = %d, want 401", rec.Code)
+	}
+	if rec := do(t, h, "POST", "/admin/loglevel", `{"level":"verbose"}`, auth...); rec.Code != http.StatusBadRequest {
+		t.Fatalf("changing to an unknown level = %d, want 400: %s", rec.Code, rec.Body)
+	}
+	rec := do(t, h, "POST", "/admin/loglevel", `{"level":"debug"}`, auth...)
+	if rec.Code != http.StatusOK || decode[logLevelBody](t, rec).Level != "debug" {
+		t.Fatalf("changing to debug = %d: %s", rec.Code, rec.Body)
+	}
+	if got := decode[logLevelBody](t, do(t, h, "GET", "/admin/loglevel", "", auth...)); got.Level != "debug" {
+		t.Errorf("GET /admin/loglevel = %q, want debug", got.Level)
+	}
+	if body, _ := requestLog()["body"].(string); !strings.Contains(body, "log me") {
+		t.Errorf("request body %q not logged at debug level", body)
+	}
+}
+
+func TestRequestBodyNotLoggedAtInfo(t *testing.T) {
+	cfg := testConfig()
+	cfg.AuthTokens = []string{"s3cret"}
+	s := newTestServer(t, cfg)
+	var logs bytes.Buffer
+	h := s.handler(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: s.logLevel})))
+	auth := []string{"Authorization", "Bearer s3cret"}
+
+	// Going to debug and back must stop body logging again, not just start it.
+	for _, level := range []string{"debug", "info"} {
+		if rec := do(t, h, "POST", "/admin/loglevel", `{"level":"`+level+`"}`, auth...); rec.Code != http.StatusOK {
+			t.Fatalf("changing to %s = %d: %s", level, rec.Code, rec.Body)
+		}
+	}
+	logs.Reset()
+	do(t, h, "POST", "/echo", `{"text":"keep this private"}`, auth...)
+	if logs.Len() == 0 {
+		t.Fatal("no request logged at info level")
+	}
+	if strings.Contains(logs.String(), "keep this private") {
+		t.Errorf("request body logged at info level: %s", logs.String())
+	}
+}
+
+func TestLogLevelNeedsAuthConfigured(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for _, method := range []string{"GET", "POST"} {
+		if rec := do(t, h, method, "/admin/loglevel", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s /admin/loglevel without tokens = %d, want 404", method, rec.Code)
+		}
+	}
+}
+
+func TestAdminShutdown(t *testing.T) {
+	cfg := testConfig()
+	cfg.AuthTokens = []string{"s3cret"}
+	cfg.AdminShutdown = true
+	cfg.DrainDelay = 0
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newTestServer(t, cfg)
+	srv := newHTTPServer(cfg, app.handler(slog.Default()))
+	done := make(chan struct{})
+	go func() {
+		waitForShutdown(srv, app, cfg.ShutdownTimeout)
+		close(done)
+	}()
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	base := "http://" + ln.Addr().String()
+	client := closingClient()
+
+	shutdown := func(token string) int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", base+"/admin/shutdown", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if code := shutdown(""); code != http.StatusUnauthorized {
+		t.Fatalf("shutdown without a token = %d, want 401", code)
+	}
+	if code := shutdown("s3cret"); code != http.StatusAccepted {
+		t.Fatalf("shutdown = %d, want 202", code)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	if conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		conn.Close()
+		t.Fatal("new connection accepted after shutdown")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tokenReloadDebounce coalesces the burst of events an editor or deploy tool
+// produces when it rewrites the token file.
+const tokenReloadDebounce = 250 * time.Millisecond
+
+// authenticator checks bearer tokens against a set of valid tokens. Tokens
+// from a file can be reloaded at runtime; see watch.
+type authenticator struct {
+	mu     sync.RWMutex
+	tokens [][]byte
+
+	static []string // tokens given directly, kept across reloads
+	file   string
+}
+
+// newAuthenticator combines the tokens given directly with those read from
+// tokensFile, if set.
+func newAuthenticator(tokens []string, tokensFile string) (*authenticator, error) {
+	a := &authenticator{static: tokens, file: tokensFile}
+	if _, err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload rereads the token file and swaps in the new token set, reporting
+// whether it differs from the current one. A file that cannot be read, or
+// that would leave no tokens where there were some, is rejected and the
+// current set kept, so a bad write cannot lock everyone out or silently
+// switch authentication off.
+func (a *authenticator) reload() (bool, error) {
+	all := a.static
+	if a.file != "" {
+		fromFile, err := readTokenFile(a.file)
+		if err != nil {
+			return false, err
+		}
+		all = append(slices.Clip(all), fromFile...)
+	}
+
+	tokens := make([][]byte, 0, len(all))
+	for _, t := range all {
+		tokens = append(tokens, []byte(t))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(tokens) == 0 && len(a.tokens) > 0 {
+		return false, fmt.Errorf("read auth tokens: %s contains no tokens", a.file)
+	}
+	changed := !slices.EqualFunc(tokens, a.tokens, bytes.Equal)
+	a.tokens = tokens
+	return changed, nil
+}
+
+// watch reloads the token file whenever it changes until ctx is cancelled.
+// The directory is watched rather than the file itself so replacements by
+// rename, as editors do, are picked up too. Any event in the directory
+// triggers a reload, not just those naming the file: Kubernetes and Docker
+// secret mounts update the file by swapping a ..data symlink next to it, so
+// the file's own name never appears in an event.
+func (a *authenticator) watch(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch auth tokens: %w", err)
+	}
+	if err := w.Add(filepath.Dir(a.file)); err != nil {
+		w.Close()
+		return fmt.Errorf("watch auth tokens: %w", err)
+	}
+
+	go func() {
+		defer w.Close()
+		debounce := time.NewTimer(tokenReloadDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				debounce.Reset(tokenReloadDebounce)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("watching auth tokens file", "path", a.file, "error", err)
+			case <-debounce.C:
+				changed, err := a.reload()
+				if err != nil {
+					slog.Warn("keeping previous auth tokens, reload failed", "path", a.file, "error", err)
+					continue
+				}
+				if changed {
+					slog.Info("reloaded auth tokens", "path", a.file)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// readTokenFile reads one token per line, ignoring blank lines and lines
+// starting with '#'.
+func readTokenFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read auth tokens: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read auth tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// enabled reports whether any tokens are configured. With none, protected
+// routes are left open.
+func (a *authenticator) enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens) > 0
+}
+
+// valid reports whether token matches a configured token. Every candidate is
+// compared in constant time so the response time does not reveal how much
+// of a token matched.
+func (a *authenticator) valid(token string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	ok := 0
+	for _, t := range a.tokens {
+		ok |= subtle.ConstantTimeCompare([]byte(token), t)
+	}
+	return ok == 1
+}
+
+// require wraps a route that needs a valid bearer token. Routes are opted in
+// individually; anything not wrapped stays public.
+func (a *authenticator) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled() {
+			next(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeJSONError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+		if !a.valid(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>"
+// header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
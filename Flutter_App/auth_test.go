@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuthRequired(t *testing.T) {
+	cfg := testConfig()
+	cfg.AuthTokens = []string{"s3cret"}
+	h := newTestServer(t, cfg).handler(slog.Default())
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		error         string
+	}{
+		{"missing token", "", http.StatusUnauthorized, "Missing bearer token"},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized, "Missing bearer token"},
+		{"invalid token", "Bearer guess", http.StatusUnauthorized, "Invalid bearer token"},
+		{"valid token", "Bearer s3cret", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.authorization != "" {
+				header = []string{"Authorization", tt.authorization}
+			}
+			rec := do(t, h, "POST", "/echo", `{"text":"hi"}`, header...)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.error == "" {
+				return
+			}
+			if got := decode[errorResponse](t, rec); got.Error != tt.error {
+				t.Errorf("error = %q, want %q", got.Error, tt.error)
+			}
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate not set")
+			}
+		})
+	}
+
+	t.Run("public route", func(t *testing.T) {
+		if rec := do(t, h, "GET", "/messages", ""); rec.Code != http.StatusOK {
+			t.Fatalf("GET /messages = %d, want 200 without a token", rec.Code)
+		}
+	})
+}
+
+func TestAuthTokenFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte("# deploy tokens\nfirst\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newAuthenticator(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.watch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if !auth.valid("first") || auth.valid("second") {
+		t.Fatal("initial token set not read from the file")
+	}
+
+	// Replace the file by rename, as editors and secret mounts do.
+	next := path + ".new"
+	if err := os.WriteFile(next, []byte("first\nsecond\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(next, path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the new token to be accepted", func() bool { return auth.valid("second") })
+
+	// A file left empty is rejected and the previous tokens kept.
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * tokenReloadDebounce)
+	if !auth.valid("first") || !auth.valid("second") {
+		t.Error("emptying the token file dropped the previous tokens")
+	}
+}
+
+func TestAuthTokenFileReloadThroughSymlinkSwap(t *testing.T) {
+	// Lay the directory out like a Kubernetes secret mount: the file is a
+	// symlink through ..data, and an update repoints ..data at a new
+	// timestamped directory, so no event ever names the file itself.
+	dir := t.TempDir()
+	writeVersion := func(name, tokens string) {
+		t.Helper()
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "tokens"), []byte(tokens), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..2026_10_14_09_00", "first\n")
+	if err := os.Symlink("..2026_10_14_09_00", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "tokens")
+	if err := os.Symlink(filepath.Join("..data", "tokens"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := newAuthenticator(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.watch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if !auth.valid("first") {
+		t.Fatal("initial token set not read through the symlinks")
+	}
+
+	writeVersion("..2026_10_14_09_05", "second\n")
+	if err := os.Symlink("..2026_10_14_09_05", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the swapped-in token to be accepted", func() bool { return auth.valid("second") })
+	if auth.valid("first") {
+		t.Error("the token from the old version is still accepted")
+	}
+}
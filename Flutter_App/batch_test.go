@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEchoBatch(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBatchSize = 3
+	s := newTestServer(t, cfg)
+	h := s.handler(slog.Default())
+
+	t.Run("all valid", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo/batch?transform=upper", `[{"text":"one"},{"text":"two"}]`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		got := decode[[]batchResult](t, rec)
+		if len(got) != 2 {
+			t.Fatalf("got %d results, want 2", len(got))
+		}
+		for i, want := range []string{"ONE", "TWO"} {
+			if got[i].Status != "ok" || got[i].Message == nil || got[i].Message.Text != want {
+				t.Errorf("result %d = %+v, want %q stored", i, got[i], want)
+			}
+		}
+	})
+
+	t.Run("one invalid item", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo/batch", `[{"text":"fine"},{"text":5},{"text":"also fine"}]`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		got := decode[[]batchResult](t, rec)
+		if len(got) != 3 || got[0].Status != "ok" || got[2].Status != "ok" {
+			t.Fatalf("results = %+v, want the valid items stored", got)
+		}
+		bad := got[1]
+		if bad.Status != "error" || len(bad.Errors) != 1 || bad.Errors[0].Index == nil || *bad.Errors[0].Index != 1 || bad.Errors[0].Field != "text" {
+			t.Errorf("result 1 = %+v, want a text error at index 1", bad)
+		}
+		if n, _ := s.store.Count(t.Context()); n != 4 {
+			t.Errorf("store holds %d messages, want the 4 valid items", n)
+		}
+	})
+
+	t.Run("too many items", func(t *testing.T) {
+		body := "[" + strings.Repeat(`{"text":"x"},`, 3) + `{"text":"x"}]`
+		rec := do(t, h, "POST", "/echo/batch", body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "batch must contain at most 3 messages" {
+			t.Errorf("error = %q", got.Error)
+		}
+		if n, _ := s.store.Count(t.Context()); n != 4 {
+			t.Errorf("store holds %d messages, want nothing from the rejected batch", n)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxChaosDelay caps the delay a client can ask for with delay_ms.
+const maxChaosDelay = 30 * time.Second
+
+// chaosDelayLimit returns the longest delay_ms accepts: maxChaosDelay, or
+// half of cfg.WriteTimeout if that is shorter, leaving the handler the other
+// half so the delayed response is still written.
+func (s *server) chaosDelayLimit() time.Duration {
+	return min(maxChaosDelay, s.cfg.WriteTimeout/2)
+}
+
+// injectDelay holds each request carrying a delay_ms query parameter for
+// that many milliseconds before handling it, so clients can exercise their
+// loading states. It is a no-op unless cfg.ChaosEnabled is set, in which
+// case delay_ms is ignored like any other unknown parameter. The wait ends
+// early if the client goes away. It runs outside the concurrency limit, so
+// delayed requests do not hold a slot while they wait.
+func (s *server) injectDelay(next http.Handler) http.Handler {
+	if !s.cfg.ChaosEnabled {
+		return next
+	}
+	limit := s.chaosDelayLimit()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get("delay_ms")
+		if v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > limit {
+			writeJSONError(w, http.StatusBadRequest,
+				fmt.Sprintf("delay_ms must be an integer from 0 to %d", limit.Milliseconds()))
+			return
+		}
+
+		timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
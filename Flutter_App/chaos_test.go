@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInjectDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	t.Run("enabled", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.ChaosEnabled = true
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		start := time.Now()
+		rec := do(t, h, "GET", "/version?delay_ms=100", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("delayed request took %s, want at least %s", elapsed, delay)
+		}
+		if rec := do(t, h, "GET", "/version?delay_ms=soon", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("delay_ms=soon = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h := newTestServer(t, testConfig()).handler(slog.Default())
+		start := time.Now()
+		if rec := do(t, h, "GET", "/version?delay_ms=soon", ""); rec.Code != http.StatusOK {
+			t.Errorf("delay_ms with chaos off = %d, want it ignored", rec.Code)
+		}
+		if rec := do(t, h, "GET", "/version?delay_ms=100", ""); rec.Code != http.StatusOK {
+			t.Errorf("delay_ms with chaos off = %d, want 200", rec.Code)
+		}
+		if elapsed := time.Since(start); elapsed >= delay {
+			t.Errorf("requests took %s with chaos off, want no delay", elapsed)
+		}
+	})
+
+	t.Run("capped below the write timeout", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.ChaosEnabled = true
+		cfg.WriteTimeout = time.Second
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		rec := do(t, h, "GET", "/version?delay_ms=501", "")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("delay past half the write timeout = %d, want 400", rec.Code)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "delay_ms must be an integer from 0 to 500" {
+			t.Errorf("error = %q", got.Error)
+		}
+	})
+
+	t.Run("outside the concurrency limit", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.ChaosEnabled = true
+		cfg.MaxConcurrent = 1
+		cfg.ConcurrencyWait = 0
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		done := make(chan int)
+		go func() { done <- do(t, h, "GET", "/version?delay_ms=300", "").Code }()
+		time.Sleep(50 * time.Millisecond)
+		if rec := do(t, h, "GET", "/version", ""); rec.Code != http.StatusOK {
+			t.Errorf("request next to a delayed one = %d, want 200 since waiting holds no slot", rec.Code)
+		}
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("delayed request = %d, want 200", code)
+		}
+	})
+}
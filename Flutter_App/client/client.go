@@ -0,0 +1,147 @@
+// Package client is a typed Go client for the echo server's HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a stored message as returned by the server.
+type Message struct {
+	ID         int64     `json:"id"`
+	Text       string    `json:"text"`
+	ReceivedAt time.Time `json:"received_at"`
+	// ExpiresAt is zero for messages that do not expire.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIError is a non-2xx response from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the server at BaseURL, e.g. "http://localhost:8080" or
+// "https://example.com/api" when it is mounted under a base path.
+type Client struct {
+	BaseURL string
+	// Token, if set, is sent as a bearer token on every request.
+	Token string
+	// HTTPClient is used for every request; http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// New returns a Client for the server at baseURL using http.DefaultClient.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// Echo posts text to /echo and returns the stored message.
+func (c *Client) Echo(ctx context.Context, text string) (Message, error) {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return Message{}, err
+	}
+	var msg Message
+	err = c.do(ctx, http.MethodPost, "/echo", nil, body, &msg)
+	return msg, err
+}
+
+// ListMessages returns up to limit stored messages starting at offset,
+// oldest first. The server caps limit at its own maximum page size.
+func (c *Client) ListMessages(ctx context.Context, limit, offset int) ([]Message, error) {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+	var page struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/messages", q, nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Messages, nil
+}
+
+// Health returns nil if the server reports itself healthy or degraded, and
+// an *APIError if it reports itself unavailable.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil, nil)
+}
+
+// do sends a request and decodes a 2xx JSON response into out, if out is
+// not nil. Any other status is returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// decodeError builds an APIError from the server's {"error": ...} body,
+// falling back to the health status or the status text for other bodies.
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Error  string `json:"error"`
+		Status any    `json:"status"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+
+	msg := body.Error
+	if s, ok := body.Status.(string); ok && msg == "" {
+		msg = s
+	}
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: msg}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newServer starts a test server running h and returns a client for it.
+func newServer(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return New(ts.URL+"/", "s3cret")
+}
+
+func TestEcho(t *testing.T) {
+	received := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/echo" {
+			t.Errorf("request = %s %s, want POST /echo", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			t.Errorf("Authorization = %q, want the bearer token", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"text":"hello"}` {
+			t.Errorf("body = %s, want the text as JSON", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":7,"text":"hello","received_at":"2026-10-14T09:30:00Z"}`)
+	})
+
+	msg, err := c.Echo(t.Context(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 7 || msg.Text != "hello" || !msg.ReceivedAt.Equal(received) || !msg.ExpiresAt.IsZero() {
+		t.Errorf("Echo = %+v, want message 7", msg)
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/messages" {
+			t.Errorf("request = %s %s, want GET /messages", r.Method, r.URL.Path)
+		}
+		if q := r.URL.Query(); q.Get("limit") != "2" || q.Get("offset") != "4" {
+			t.Errorf("query = %s, want limit=2 and offset=4", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"messages": []Message{{ID: 5, Text: "five"}, {ID: 6, Text: "six"}},
+			"total":    6, "limit": 2, "offset": 4,
+		})
+	})
+
+	msgs, err := c.ListMessages(t.Context(), 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != 5 || msgs[1].Text != "six" {
+		t.Errorf("ListMessages = %+v, want messages 5 and 6", msgs)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	status := "degraded"
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("path = %s, want /health", r.URL.Path)
+		}
+		if status == "unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status, "uptime_seconds": 3})
+	})
+
+	if err := c.Health(t.Context()); err != nil {
+		t.Errorf("Health on a degraded server = %v, want nil", err)
+	}
+	status = "unavailable"
+	var apiErr *APIError
+	if err := c.Health(t.Context()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "unavailable" {
+		t.Errorf("Health on an unavailable server = %v, want a 503 APIError", err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"error body", http.StatusUnauthorized, `{"error":"Invalid bearer token","status":401}`, "Invalid bearer token"},
+		{"validation body", http.StatusUnprocessableEntity, `{"error":"Validation failed","status":422,"errors":[]}`, "Validation failed"},
+		{"not JSON", http.StatusBadGateway, "<html>bad gateway</html>", "Bad Gateway"},
+		{"empty", http.StatusInternalServerError, "", "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+
+			_, err := c.Echo(t.Context(), "hi")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Echo error = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.want {
+				t.Errorf("APIError = %d %q, want %d %q", apiErr.StatusCode, apiErr.Message, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id":`)
+	})
+	_, err := c.Echo(t.Context(), "hi")
+	var apiErr *APIError
+	if err == nil || errors.As(err, &apiErr) {
+		t.Errorf("Echo on a truncated 200 = %v, want a decoding error", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// unlimitedPaths are exempt from the concurrency limit: the probes so an
+// overloaded server can still be observed, and the event stream because its
+// long-lived, mostly idle connections would otherwise pin slots.
+var unlimitedPaths = map[string]bool{
+	"/health":  true,
+	"/ready":   true,
+	"/metrics": true,
+	"/events":  true,
+}
+
+// concurrencyLimiter caps how many requests are served at once, using a
+// buffered channel as a semaphore.
+type concurrencyLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newConcurrencyLimiter allows max concurrent requests. A request that finds
+// every slot taken waits up to wait for one to free up.
+func newConcurrencyLimiter(max int, wait time.Duration) *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(chan struct{}, max), wait: wait}
+}
+
+// inFlight returns the number of slots currently taken.
+func (c *concurrencyLimiter) inFlight() int {
+	return len(c.slots)
+}
+
+// limit rejects requests with a 503 and Retry-After when no slot frees up in
+// time. It must run after the base path is stripped so unlimitedPaths match.
+func (c *concurrencyLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(c.wait)
+			defer timer.Stop()
+			select {
+			case c.slots <- struct{}{}:
+			case <-timer.C:
+				w.Header().Set("Retry-After", "1")
+				writeJSONError(w, http.StatusServiceUnavailable, "Server is busy")
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-c.slots }()
+		next.ServeHTTP(w, r)
+	})
+}
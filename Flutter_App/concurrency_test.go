@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	c := newConcurrencyLimiter(1, 10*time.Millisecond)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := c.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+	}))
+
+	done := make(chan int)
+	go func() { done <- do(t, h, "GET", "/slow", "").Code }()
+	<-entered
+	if n := c.inFlight(); n != 1 {
+		t.Errorf("inFlight = %d, want 1", n)
+	}
+
+	rec := do(t, h, "GET", "/version", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("request over the limit = %d (Retry-After %q), want 503 and Retry-After 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := do(t, h, "GET", "/health", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /health while saturated = %d, want 200", rec.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("slot holder = %d, want 200", code)
+	}
+	if rec := do(t, h, "GET", "/version", ""); rec.Code != http.StatusOK {
+		t.Errorf("request after the slot freed = %d, want 200", rec.Code)
+	}
+}
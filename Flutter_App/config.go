@@ -0,0 +1,522 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultAddr         = ":8080"
+	defaultMaxBodyBytes = 1 << 20
+	defaultMaxMsgLength = 4096
+	defaultMaxStored    = 1000
+	defaultMaxBatchSize = 100
+	defaultSQLitePath   = "messages.db"
+	defaultGzipMinSize  = 1024
+	defaultUploadDir    = "uploads"
+	defaultUploadMax    = 5 << 20
+	defaultUploadMemory = 1 << 20
+)
+
+// Config holds the runtime settings for the server.
+//
+// Settings are layered: built-in defaults, then the YAML file named by
+// -config, then environment variables, then command-line flags.
+type Config struct {
+	Addr string `yaml:"addr"`
+	// ReusePort binds the listener with SO_REUSEPORT, so a new process can
+	// start listening on the same port while the old one drains. Linux and
+	// the BSDs only.
+	ReusePort       bool          `yaml:"reuse_port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long /ready reports 503 before the server stops
+	// accepting connections, giving load balancers time to take the
+	// instance out of rotation.
+	DrainDelay   time.Duration `yaml:"drain_delay"`
+	LogLevel     slog.Level    `yaml:"log_level"`
+	CORSOrigins  []string      `yaml:"cors_origins"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"`
+	// MaxMessageLength is the longest accepted message text, in runes.
+	MaxMessageLength int `yaml:"max_message_length"`
+	// MaxStoredMessages caps the in-memory store; the oldest messages are
+	// evicted first.
+	MaxStoredMessages int `yaml:"max_stored_messages"`
+	// MaxBatchSize is the most messages accepted by one POST /echo/batch.
+	MaxBatchSize int `yaml:"max_batch_size"`
+	// Storage selects the message store backend: "memory" or "sqlite".
+	Storage    string `yaml:"storage"`
+	SQLitePath string `yaml:"sqlite_path"`
+	// SQLiteAttempts is how many times a SQLite write is tried while the
+	// database reports it is busy or locked.
+	SQLiteAttempts int `yaml:"sqlite_attempts"`
+	// StoreTimeout bounds each store call made while serving a request.
+	StoreTimeout time.Duration `yaml:"store_timeout"`
+	// MessageTTL is how long messages are kept unless POST /echo asks for
+	// another TTL with ttl_seconds; zero keeps them until cleared. Expired
+	// messages are hidden at once and deleted every ExpiryInterval.
+	MessageTTL     time.Duration `yaml:"message_ttl"`
+	ExpiryInterval time.Duration `yaml:"expiry_interval"`
+	// AuthTokens and the tokens listed in AuthTokensFile are accepted as
+	// bearer tokens on protected routes.
+	AuthTokens     []string `yaml:"auth_tokens"`
+	AuthTokensFile string   `yaml:"auth_tokens_file"`
+	// AdminShutdown enables POST /admin/shutdown. It requires auth tokens,
+	// since anyone who can call it can stop the server.
+	AdminShutdown bool `yaml:"admin_shutdown"`
+	// EnablePprof serves the runtime profiles under /debug/pprof/ to
+	// authenticated clients. Like AdminShutdown it requires auth tokens.
+	EnablePprof bool `yaml:"enable_pprof"`
+	// ChaosEnabled lets clients delay their own requests with a delay_ms
+	// query parameter, for testing how they handle a slow server. Delays
+	// are capped at half of WriteTimeout. Never enable it in production.
+	ChaosEnabled bool `yaml:"chaos_enabled"`
+	// RateLimit is the sustained number of requests per second allowed per
+	// client on rate-limited routes, with bursts of up to RateBurst.
+	RateLimit float64 `yaml:"rate_limit"`
+	RateBurst int     `yaml:"rate_burst"`
+	// MaxConcurrent caps the requests served at once. A request that cannot
+	// get a slot within ConcurrencyWait is answered with 503.
+	MaxConcurrent   int           `yaml:"max_concurrent"`
+	ConcurrencyWait time.Duration `yaml:"concurrency_wait"`
+	// IdempotencyTTL is how long the response to a POST /echo carrying an
+	// Idempotency-Key is replayed for retries with the same key.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+	// TrustProxy makes the server believe X-Forwarded-* headers. Only enable
+	// it behind a proxy that overwrites them.
+	TrustProxy bool `yaml:"trust_proxy"`
+	// GzipMinSize is the smallest response, in bytes, that is gzipped.
+	GzipMinSize int `yaml:"gzip_min_size"`
+	// StatsInterval is the window over which GET /stats measures the
+	// message rate, and how often it is logged.
+	StatsInterval time.Duration `yaml:"stats_interval"`
+	// WebhookURL, when set, receives a POST of every stored message. Sinks
+	// are fed by SinkWorkers workers from a queue of SinkQueueSize messages;
+	// messages beyond that are dropped.
+	WebhookURL     string        `yaml:"webhook_url"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+	SinkWorkers    int           `yaml:"sink_workers"`
+	SinkQueueSize  int           `yaml:"sink_queue_size"`
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// EnableH2C serves HTTP/2 over cleartext next to HTTP/1.1, for local
+	// development with clients that need HTTP/2 but no TLS.
+	EnableH2C bool `yaml:"enable_h2c"`
+	// BasePath mounts every route under a prefix such as /api, for
+	// deployments behind a reverse proxy. ProbesAtRoot keeps /health and
+	// /metrics reachable at the root as well.
+	BasePath     string `yaml:"base_path"`
+	ProbesAtRoot bool   `yaml:"probes_at_root"`
+	// TrailingSlash chooses how a path with a trailing slash is handled:
+	// "redirect" sends GET and HEAD requests a 301 to the path without it,
+	// "rewrite" serves every request as if the slash were absent.
+	TrailingSlash string `yaml:"trailing_slash"`
+	// UploadDir is where POST /upload stores files. Uploads larger than
+	// UploadMaxBytes are rejected, and parts beyond UploadMemoryBytes are
+	// spooled to temporary files while the form is parsed.
+	UploadDir          string   `yaml:"upload_dir"`
+	UploadMaxBytes     int64    `yaml:"upload_max_bytes"`
+	UploadMemoryBytes  int64    `yaml:"upload_memory_bytes"`
+	UploadAllowedTypes []string `yaml:"upload_allowed_types"`
+
+	// HandlerTimeout bounds each non-streaming handler; UploadTimeout
+	// replaces it for POST /upload. Both are further limited by ReadTimeout
+	// and WriteTimeout, which apply to the connection.
+	HandlerTimeout time.Duration `yaml:"handler_timeout"`
+	UploadTimeout  time.Duration `yaml:"upload_timeout"`
+
+	// The http.Server's connection timeouts. WriteTimeout also bounds each
+	// write to an event stream, which as a whole may run for much longer.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+}
+
+// defaultConfig returns the base layer that the file, environment and flags
+// are applied on top of.
+func defaultConfig() Config {
+	return Config{
+		Addr:              defaultAddr,
+		ShutdownTimeout:   10 * time.Second,
+		LogLevel:          slog.LevelInfo,
+		MaxBodyBytes:      defaultMaxBodyBytes,
+		MaxMessageLength:  defaultMaxMsgLength,
+		MaxStoredMessages: defaultMaxStored,
+		MaxBatchSize:      defaultMaxBatchSize,
+		Storage:           "memory",
+		SQLitePath:        defaultSQLitePath,
+		SQLiteAttempts:    5,
+		StoreTimeout:      2 * time.Second,
+		ExpiryInterval:    time.Minute,
+		RateLimit:         5,
+		RateBurst:         10,
+		MaxConcurrent:     256,
+		ConcurrencyWait:   100 * time.Millisecond,
+		IdempotencyTTL:    24 * time.Hour,
+		GzipMinSize:       defaultGzipMinSize,
+		StatsInterval:     time.Minute,
+		TrailingSlash:     "redirect",
+		WebhookTimeout:    5 * time.Second,
+		SinkWorkers:       4,
+		SinkQueueSize:     1000,
+		UploadDir:         defaultUploadDir,
+		UploadMaxBytes:    defaultUploadMax,
+		UploadMemoryBytes: defaultUploadMemory,
+		UploadAllowedTypes: []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp",
+		},
+		HandlerTimeout:    10 * time.Second,
+		UploadTimeout:     30 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
+
+// envFallbacks maps each flag to the environment variable consulted when the
+// flag is not given on the command line.
+var envFallbacks = map[string]string{
+	"addr":                "LISTEN_ADDR",
+	"reuse-port":          "REUSE_PORT",
+	"shutdown-timeout":    "SHUTDOWN_TIMEOUT",
+	"drain-delay":         "DRAIN_DELAY",
+	"log-level":           "LOG_LEVEL",
+	"cors-origins":        "CORS_ORIGINS",
+	"max-body-bytes":      "MAX_BODY_BYTES",
+	"max-message-length":  "MAX_MESSAGE_LENGTH",
+	"max-stored-messages": "MAX_STORED_MESSAGES",
+	"max-batch-size":      "MAX_BATCH_SIZE",
+	"storage":             "STORAGE",
+	"sqlite-path":         "SQLITE_PATH",
+	"sqlite-attempts":     "SQLITE_ATTEMPTS",
+	"store-timeout":       "STORE_TIMEOUT",
+	"message-ttl":         "MESSAGE_TTL",
+	"expiry-interval":     "EXPIRY_INTERVAL",
+	"auth-tokens":         "AUTH_TOKENS",
+	"auth-tokens-file":    "AUTH_TOKENS_FILE",
+	"admin-shutdown":      "ADMIN_SHUTDOWN",
+	"enable-pprof":        "ENABLE_PPROF",
+	"chaos-enabled":       "CHAOS_ENABLED",
+	"rate-limit":          "RATE_LIMIT",
+	"rate-burst":          "RATE_BURST",
+	"max-concurrent":      "MAX_CONCURRENT",
+	"concurrency-wait":    "CONCURRENCY_WAIT",
+	"idempotency-ttl":     "IDEMPOTENCY_TTL",
+	"trust-proxy":         "TRUST_PROXY",
+	"gzip-min-size":       "GZIP_MIN_SIZE",
+	"stats-interval":      "STATS_INTERVAL",
+	"webhook-url":         "WEBHOOK_URL",
+	"webhook-timeout":     "WEBHOOK_TIMEOUT",
+	"sink-workers":        "SINK_WORKERS",
+	"sink-queue-size":     "SINK_QUEUE_SIZE",
+	"tls-cert-file":       "TLS_CERT_FILE",
+	"tls-key-file":        "TLS_KEY_FILE",
+	"enable-h2c":          "ENABLE_H2C",
+	"base-path":           "BASE_PATH",
+	"probes-at-root":      "PROBES_AT_ROOT",
+	"trailing-slash":      "TRAILING_SLASH",
+	"upload-dir":          "UPLOAD_DIR",
+	"upload-max-bytes":    "UPLOAD_MAX_BYTES",
+	"upload-memory-bytes": "UPLOAD_MEMORY_BYTES",
+	"upload-types":        "UPLOAD_TYPES",
+
+	"handler-timeout":     "HANDLER_TIMEOUT",
+	"upload-timeout":      "UPLOAD_TIMEOUT",
+	"read-header-timeout": "READ_HEADER_TIMEOUT",
+	"read-timeout":        "READ_TIMEOUT",
+	"write-timeout":       "WRITE_TIMEOUT",
+	"idle-timeout":        "IDLE_TIMEOUT",
+}
+
+// newFlagSet binds a flag to every Config field. Flag defaults are taken
+// from cfg, so they show up in -help.
+func newFlagSet(cfg *Config, configFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(configFile, "config", "", "YAML config file; environment variables and flags override it")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "listen address (env LISTEN_ADDR)")
+	fs.BoolVar(&cfg.ReusePort, "reuse-port", cfg.ReusePort, "listen with SO_REUSEPORT for zero-downtime restarts; Linux and BSD only (env REUSE_PORT)")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long to wait for in-flight requests on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.DurationVar(&cfg.DrainDelay, "drain-delay", cfg.DrainDelay, "how long /ready fails before shutdown starts draining connections (env DRAIN_DELAY)")
+	fs.Var(levelFlag{&cfg.LogLevel}, "log-level", "log level: info or debug (env LOG_LEVEL)")
+	fs.Var(listFlag{&cfg.CORSOrigins}, "cors-origins", "comma-separated list of allowed CORS origins (env CORS_ORIGINS)")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", cfg.MaxBodyBytes, "maximum request body size in bytes (env MAX_BODY_BYTES)")
+	fs.IntVar(&cfg.MaxMessageLength, "max-message-length", cfg.MaxMessageLength, "maximum message text length in characters (env MAX_MESSAGE_LENGTH)")
+	fs.IntVar(&cfg.MaxStoredMessages, "max-stored-messages", cfg.MaxStoredMessages, "number of messages kept in memory (env MAX_STORED_MESSAGES)")
+	fs.IntVar(&cfg.MaxBatchSize, "max-batch-size", cfg.MaxBatchSize, "maximum number of messages in one /echo/batch request (env MAX_BATCH_SIZE)")
+	fs.StringVar(&cfg.Storage, "storage", cfg.Storage, "message storage backend: memory or sqlite (env STORAGE)")
+	fs.StringVar(&cfg.SQLitePath, "sqlite-path", cfg.SQLitePath, "SQLite database file used by the sqlite backend (env SQLITE_PATH)")
+	fs.IntVar(&cfg.SQLiteAttempts, "sqlite-attempts", cfg.SQLiteAttempts, "attempts for a SQLite write while the database is busy (env SQLITE_ATTEMPTS)")
+	fs.DurationVar(&cfg.StoreTimeout, "store-timeout", cfg.StoreTimeout, "time allowed for each message store call (env STORE_TIMEOUT)")
+	fs.DurationVar(&cfg.MessageTTL, "message-ttl", cfg.MessageTTL, "default time messages are kept; 0 keeps them until cleared (env MESSAGE_TTL)")
+	fs.DurationVar(&cfg.ExpiryInterval, "expiry-interval", cfg.ExpiryInterval, "how often expired messages are deleted (env EXPIRY_INTERVAL)")
+	fs.Var(listFlag{&cfg.AuthTokens}, "auth-tokens", "comma-separated bearer tokens accepted on protected routes (env AUTH_TOKENS)")
+	fs.StringVar(&cfg.AuthTokensFile, "auth-tokens-file", cfg.AuthTokensFile, "file with one bearer token per line, reloaded when it changes (env AUTH_TOKENS_FILE)")
+	fs.BoolVar(&cfg.AdminShutdown, "admin-shutdown", cfg.AdminShutdown, "enable POST /admin/shutdown; requires auth tokens (env ADMIN_SHUTDOWN)")
+	fs.BoolVar(&cfg.EnablePprof, "enable-pprof", cfg.EnablePprof, "serve profiling data under /debug/pprof/; requires auth tokens (env ENABLE_PPROF)")
+	fs.BoolVar(&cfg.ChaosEnabled, "chaos-enabled", cfg.ChaosEnabled, "honour the delay_ms query parameter to slow down requests, for testing only (env CHAOS_ENABLED)")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "requests per second allowed per client on /echo (env RATE_LIMIT)")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", cfg.RateBurst, "burst size for the per-client rate limit (env RATE_BURST)")
+	fs.IntVar(&cfg.MaxConcurrent, "max-concurrent", cfg.MaxConcurrent, "maximum number of requests served at once (env MAX_CONCURRENT)")
+	fs.DurationVar(&cfg.ConcurrencyWait, "concurrency-wait", cfg.ConcurrencyWait, "how long a request waits for a free slot before a 503 (env CONCURRENCY_WAIT)")
+	fs.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", cfg.IdempotencyTTL, "how long Idempotency-Key responses are kept for replay (env IDEMPOTENCY_TTL)")
+	fs.BoolVar(&cfg.TrustProxy, "trust-proxy", cfg.TrustProxy, "trust X-Forwarded-* headers from a reverse proxy (env TRUST_PROXY)")
+	fs.IntVar(&cfg.GzipMinSize, "gzip-min-size", cfg.GzipMinSize, "smallest response size in bytes that is gzip-compressed (env GZIP_MIN_SIZE)")
+	fs.DurationVar(&cfg.StatsInterval, "stats-interval", cfg.StatsInterval, "how often message throughput is measured and logged (env STATS_INTERVAL)")
+	fs.StringVar(&cfg.WebhookURL, "webhook-url", cfg.WebhookURL, "URL every stored message is POSTed to (env WEBHOOK_URL)")
+	fs.DurationVar(&cfg.WebhookTimeout, "webhook-timeout", cfg.WebhookTimeout, "time allowed for each webhook delivery (env WEBHOOK_TIMEOUT)")
+	fs.IntVar(&cfg.SinkWorkers, "sink-workers", cfg.SinkWorkers, "workers forwarding stored messages to sinks (env SINK_WORKERS)")
+	fs.IntVar(&cfg.SinkQueueSize, "sink-queue-size", cfg.SinkQueueSize, "messages waiting for sink delivery before new ones are dropped (env SINK_QUEUE_SIZE)")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert-file", cfg.TLSCertFile, "PEM certificate file; serves HTTPS together with -tls-key-file (env TLS_CERT_FILE)")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key-file", cfg.TLSKeyFile, "PEM private key file for -tls-cert-file (env TLS_KEY_FILE)")
+	fs.BoolVar(&cfg.EnableH2C, "enable-h2c", cfg.EnableH2C, "also serve HTTP/2 over cleartext, for local development (env ENABLE_H2C)")
+	fs.StringVar(&cfg.BasePath, "base-path", cfg.BasePath, "URL prefix all routes are mounted under, e.g. /api (env BASE_PATH)")
+	fs.BoolVar(&cfg.ProbesAtRoot, "probes-at-root", cfg.ProbesAtRoot, "also serve /health and /metrics at the root when a base path is set (env PROBES_AT_ROOT)")
+	fs.StringVar(&cfg.TrailingSlash, "trailing-slash", cfg.TrailingSlash, "handling of trailing slashes: redirect or rewrite (env TRAILING_SLASH)")
+	fs.StringVar(&cfg.UploadDir, "upload-dir", cfg.UploadDir, "directory uploaded files are stored in (env UPLOAD_DIR)")
+	fs.Int64Var(&cfg.UploadMaxBytes, "upload-max-bytes", cfg.UploadMaxBytes, "maximum uploaded file size in bytes (env UPLOAD_MAX_BYTES)")
+	fs.Int64Var(&cfg.UploadMemoryBytes, "upload-memory-bytes", cfg.UploadMemoryBytes, "bytes of a multipart form held in memory before spooling to disk (env UPLOAD_MEMORY_BYTES)")
+	fs.Var(listFlag{&cfg.UploadAllowedTypes}, "upload-types", "comma-separated content types accepted by /upload (env UPLOAD_TYPES)")
+	fs.DurationVar(&cfg.HandlerTimeout, "handler-timeout", cfg.HandlerTimeout, "time allowed for a handler to produce its response (env HANDLER_TIMEOUT)")
+	fs.DurationVar(&cfg.UploadTimeout, "upload-timeout", cfg.UploadTimeout, "handler timeout for /upload (env UPLOAD_TIMEOUT)")
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", cfg.ReadHeaderTimeout, "time allowed to read request headers (env READ_HEADER_TIMEOUT)")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "time allowed to read an entire request (env READ_TIMEOUT)")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "time allowed to write a response (env WRITE_TIMEOUT)")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "how long keep-alive connections may sit idle (env IDLE_TIMEOUT)")
+	return fs
+}
+
+// loadConfig builds the merged Config from defaults, the optional YAML file,
+// environment variables and command-line flags, in increasing order of
+// precedence, and validates the result.
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+	var configFile string
+
+	fs := newFlagSet(&cfg, &configFile)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// Parsing has already written the flags into cfg. Remember them, rebuild
+	// the lower layers underneath, then re-apply them on top.
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
+
+	cfg = defaultConfig()
+	if configFile != "" {
+		if err := loadConfigFile(configFile, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := applyEnv(fs, explicit); err != nil {
+		return cfg, err
+	}
+	for name, v := range explicit {
+		if name == "config" {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return cfg, fmt.Errorf("invalid -%s %q: %v", name, v, err)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadConfigFile decodes the YAML file at path over cfg. Keys that do not
+// match a Config field are rejected so typos are not silently ignored.
+func loadConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the fully merged config and reports every problem at
+// once.
+func (c Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if err := validateAddr(c.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	check(!c.ReusePort || reusePortSupported, "reuse port is not supported on %s", runtime.GOOS)
+	check(c.LogLevel == slog.LevelInfo || c.LogLevel == slog.LevelDebug,
+		"invalid log level %q: must be info or debug", c.LogLevel)
+	check(c.ShutdownTimeout > 0, "invalid shutdown timeout %s: must be positive", c.ShutdownTimeout)
+	check(c.DrainDelay >= 0, "invalid drain delay %s: must not be negative", c.DrainDelay)
+	check(c.HandlerTimeout > 0, "invalid handler timeout %s: must be positive", c.HandlerTimeout)
+	check(c.UploadTimeout > 0, "invalid upload timeout %s: must be positive", c.UploadTimeout)
+	check(c.ReadHeaderTimeout > 0, "invalid read header timeout %s: must be positive", c.ReadHeaderTimeout)
+	check(c.ReadTimeout > 0, "invalid read timeout %s: must be positive", c.ReadTimeout)
+	check(c.WriteTimeout > 0, "invalid write timeout %s: must be positive", c.WriteTimeout)
+	check(c.IdleTimeout > 0, "invalid idle timeout %s: must be positive", c.IdleTimeout)
+	check(c.StoreTimeout > 0, "invalid store timeout %s: must be positive", c.StoreTimeout)
+	check(c.MessageTTL >= 0, "invalid message TTL %s: must not be negative", c.MessageTTL)
+	check(c.ExpiryInterval > 0, "invalid expiry interval %s: must be positive", c.ExpiryInterval)
+	check(c.MaxBodyBytes > 0, "invalid max body size %d: must be positive", c.MaxBodyBytes)
+	check(c.MaxMessageLength > 0, "invalid max message length %d: must be positive", c.MaxMessageLength)
+	check(c.MaxStoredMessages > 0, "invalid max stored messages %d: must be positive", c.MaxStoredMessages)
+	check(c.MaxBatchSize > 0, "invalid max batch size %d: must be positive", c.MaxBatchSize)
+	check(c.RateLimit > 0 && c.RateBurst > 0,
+		"invalid rate limit %g/s burst %d: both must be positive", c.RateLimit, c.RateBurst)
+	check(!c.AdminShutdown || c.authConfigured(), "admin shutdown requires AUTH_TOKENS or AUTH_TOKENS_FILE")
+	check(!c.EnablePprof || c.authConfigured(), "pprof requires AUTH_TOKENS or AUTH_TOKENS_FILE")
+	check(c.MaxConcurrent > 0, "invalid max concurrent requests %d: must be positive", c.MaxConcurrent)
+	check(c.ConcurrencyWait >= 0, "invalid concurrency wait %s: must not be negative", c.ConcurrencyWait)
+	check(c.IdempotencyTTL > 0, "invalid idempotency TTL %s: must be positive", c.IdempotencyTTL)
+	check(c.StatsInterval > 0, "invalid stats interval %s: must be positive", c.StatsInterval)
+	if c.WebhookURL != "" {
+		u, err := url.Parse(c.WebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"invalid webhook URL %q: must be an absolute http or https URL", c.WebhookURL)
+	}
+	check(c.WebhookTimeout > 0, "invalid webhook timeout %s: must be positive", c.WebhookTimeout)
+	check(c.SinkWorkers > 0, "invalid sink workers %d: must be positive", c.SinkWorkers)
+	check(c.SinkQueueSize > 0, "invalid sink queue size %d: must be positive", c.SinkQueueSize)
+	check(c.GzipMinSize >= 0, "invalid gzip min size %d: must not be negative", c.GzipMinSize)
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""),
+		"TLS requires both TLS_CERT_FILE and TLS_KEY_FILE, only one is set")
+	check(!c.EnableH2C || !c.TLSEnabled(), "h2c cannot be combined with TLS, which negotiates HTTP/2 itself")
+	check(c.BasePath == "" || (strings.HasPrefix(c.BasePath, "/") && !strings.HasSuffix(c.BasePath, "/")),
+		"invalid base path %q: must start with / and not end with /", c.BasePath)
+	check(c.TrailingSlash == "redirect" || c.TrailingSlash == "rewrite",
+		"invalid trailing slash mode %q: must be redirect or rewrite", c.TrailingSlash)
+	check(c.UploadDir != "", "upload directory must not be empty")
+	check(c.UploadMaxBytes > 0, "invalid upload max size %d: must be positive", c.UploadMaxBytes)
+	check(c.UploadMemoryBytes > 0, "invalid upload memory size %d: must be positive", c.UploadMemoryBytes)
+	check(len(c.UploadAllowedTypes) > 0, "at least one upload content type must be allowed")
+	check(c.Storage == "memory" || c.Storage == "sqlite",
+		"invalid storage backend %q: must be memory or sqlite", c.Storage)
+	check(c.Storage != "sqlite" || c.SQLitePath != "", "sqlite storage requires a SQLite path")
+	check(c.SQLiteAttempts > 0, "invalid sqlite attempts %d: must be positive", c.SQLiteAttempts)
+
+	return errors.Join(errs...)
+}
+
+// authConfigured reports whether any auth tokens are configured, which the
+// admin and profiling routes require.
+func (c Config) authConfigured() bool {
+	return len(c.AuthTokens) > 0 || c.AuthTokensFile != ""
+}
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// applyEnv sets every flag that was not passed explicitly from its
+// environment variable in envFallbacks, if that variable is present.
+func applyEnv(fs *flag.FlagSet, explicit map[string]string) error {
+	for name, env := range envFallbacks {
+		v, ok := os.LookupEnv(env)
+		if _, set := explicit[name]; !ok || set {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", env, v, err)
+		}
+	}
+	return nil
+}
+
+// parseLogLevel maps a level name to a slog.Level. An empty name means info.
+func parseLogLevel(name string) (slog.Level, error) {
+	switch name {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: must be info or debug", name)
+}
+
+// levelFlag is a flag.Value for a slog.Level limited to info and debug.
+type levelFlag struct{ level *slog.Level }
+
+func (f levelFlag) String() string {
+	if f.level == nil {
+		return ""
+	}
+	return strings.ToLower(f.level.String())
+}
+
+func (f levelFlag) Set(s string) error {
+	level, err := parseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	*f.level = level
+	return nil
+}
+
+// listFlag is a flag.Value for a comma-separated list.
+type listFlag struct{ list *[]string }
+
+func (f listFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f listFlag) Set(s string) error {
+	*f.list = splitList(s)
+	return nil
+}
+
+// validateAddr checks that addr is a host:port pair with a numeric port in
+// the valid TCP range. Port 0 is allowed and picks an ephemeral port.
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid listen address %q: port must be a number between 0 and 65535", addr)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated value, trimming whitespace and
+// dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearConfigEnv unsets every configuration variable for the test, so the
+// environment the tests run in cannot leak into them.
+func clearConfigEnv(t *testing.T) {
+	for _, env := range envFallbacks {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+}
+
+// writeConfigFile writes a YAML config file and returns its path.
+func writeConfigFile(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := defaultConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("loadConfig() = %+v, want the defaults %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+addr: ":9090"
+max_batch_size: 7
+store_timeout: 3s
+cors_origins:
+  - https://app.example
+`)
+	cfg, err := loadConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9090" || cfg.MaxBatchSize != 7 || cfg.StoreTimeout != 3*time.Second {
+		t.Errorf("file values not applied: addr %q, max batch %d, store timeout %s", cfg.Addr, cfg.MaxBatchSize, cfg.StoreTimeout)
+	}
+	if !slices.Equal(cfg.CORSOrigins, []string{"https://app.example"}) {
+		t.Errorf("CORSOrigins = %v, want the file's list", cfg.CORSOrigins)
+	}
+	if cfg.MaxBodyBytes != defaultMaxBodyBytes {
+		t.Errorf("MaxBodyBytes = %d, want the default for a key the file leaves out", cfg.MaxBodyBytes)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "addr: \":9090\"\nmax_batch_size: 7\nrate_burst: 3\n")
+	t.Setenv("LISTEN_ADDR", ":9191")
+	t.Setenv("MAX_BATCH_SIZE", "8")
+
+	cfg, err := loadConfig([]string{"-config", path, "-max-batch-size", "9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9191" {
+		t.Errorf("Addr = %q, want the environment to override the file", cfg.Addr)
+	}
+	if cfg.MaxBatchSize != 9 {
+		t.Errorf("MaxBatchSize = %d, want the flag to override the environment", cfg.MaxBatchSize)
+	}
+	if cfg.RateBurst != 3 {
+		t.Errorf("RateBurst = %d, want the file value where nothing overrides it", cfg.RateBurst)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "adrr: \":9090\"\n")
+	_, err := loadConfig([]string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), "adrr") {
+		t.Fatalf("loadConfig error = %v, want one naming the unknown key", err)
+	}
+}
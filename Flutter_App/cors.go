@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Content-Encoding, Authorization, X-Request-ID, Idempotency-Key"
+	corsMaxAge        = "600"
+	corsExposeHeaders = "X-Request-ID, Idempotent-Replayed, ETag, X-Message-Count"
+)
+
+// cors allows cross-origin requests from the given origins only. The
+// request's Origin is echoed back when it is on the allowlist; anything else
+// gets no CORS headers, so an empty allowlist means same-origin only.
+// Preflight OPTIONS requests are answered directly with a 204.
+func cors(allowed []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		ok := slices.Contains(allowed, origin)
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			if !ok {
+				writeJSONError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if ok {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	const allowed = "http://localhost:3000"
+	h := cors([]string{allowed}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("allowed origin", func(t *testing.T) {
+		rec := do(t, h, "GET", "/messages", "", "Origin", allowed)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != allowed {
+			t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, allowed)
+		}
+		if rec.Header().Get("Access-Control-Expose-Headers") == "" {
+			t.Error("Access-Control-Expose-Headers not set")
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		rec := do(t, h, "GET", "/messages", "", "Origin", "http://evil.example")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		rec := do(t, h, "OPTIONS", "/echo", "", "Origin", allowed, "Access-Control-Request-Method", "POST")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != allowed {
+			t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, allowed)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != corsAllowMethods {
+			t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, corsAllowMethods)
+		}
+	})
+
+	t.Run("preflight from disallowed origin", func(t *testing.T) {
+		rec := do(t, h, "OPTIONS", "/echo", "", "Origin", "http://evil.example", "Access-Control-Request-Method", "POST")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403", rec.Code)
+		}
+	})
+}
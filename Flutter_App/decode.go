@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// requestError is a client error with the status code it should be reported
+// with.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...any) *requestError {
+	return &requestError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// writeRequestError reports err to the client, using its status when it is a
+// *requestError and 400 otherwise.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var re *requestError
+	if errors.As(err, &re) {
+		writeJSONError(w, re.status, re.msg)
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, err.Error())
+}
+
+// decodeJSONBody decodes exactly one JSON value from the request body into
+// dst, capped at maxBytes. Unknown fields are rejected. Decoder errors are
+// translated into messages that do not leak Go type names.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	if err := requireJSON(r); err != nil {
+		return err
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return translateDecodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return translateDecodeError(err)
+		}
+		return badRequest("body must contain a single JSON value")
+	}
+	return nil
+}
+
+// requireJSON rejects a request whose Content-Type is set to anything other
+// than application/json, with any parameters such as charset. A missing
+// Content-Type is taken to mean JSON.
+func requireJSON(r *http.Request) error {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(ct); err == nil && mediaType == "application/json" {
+		return nil
+	}
+	return &requestError{
+		status: http.StatusUnsupportedMediaType,
+		msg:    fmt.Sprintf("Content-Type %q is not supported, use application/json", ct),
+	}
+}
+
+func translateDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	var reqErr *requestError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return badRequest("body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("body is not valid JSON")
+	case errors.As(err, &syntaxErr):
+		return badRequest("body is not valid JSON (at byte %d)", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return badRequest("body must be a JSON %s", jsonKind(typeErr.Type))
+		}
+		return badRequest("field %s must be a %s", typeErr.Field, jsonKind(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this case.
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return badRequest("unknown field %s", field)
+	case errors.As(err, &tooLarge):
+		return &requestError{
+			status: http.StatusRequestEntityTooLarge,
+			msg:    fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit),
+		}
+	case errors.As(err, &reqErr):
+		// Raised by a body reader, e.g. a corrupt compressed body.
+		return reqErr
+	}
+	return badRequest("Error reading request body")
+}
+
+// jsonKind names the JSON type that corresponds to the Go type t.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyTooLarge(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxBodyBytes = 64
+	h := newTestServer(t, cfg).handler(slog.Default())
+
+	rec := do(t, h, "POST", "/echo", `{"text":"`+strings.Repeat("a", 100)+`"}`)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body)
+	}
+	if got := decode[errorResponse](t, rec); got.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("body status = %d, want 413", got.Status)
+	}
+}
+
+func TestDecodeJSONBodyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", "body must not be empty"},
+		{"truncated", `{"text":`, "body is not valid JSON"},
+		{"syntax error", `{"text" "hi"}`, "body is not valid JSON (at byte 9)"},
+		{"wrong field type", `{"text":5}`, "field text must be a string"},
+		{"wrong body type", `["hi"]`, "body must be a JSON object"},
+		{"unknown field", `{"text":"hi","colour":"red"}`, `unknown field "colour"`},
+		{"trailing value", `{"text":"hi"}{"text":"again"}`, "body must contain a single JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/echo", strings.NewReader(tt.body))
+			var msg Message
+			err := decodeJSONBody(httptest.NewRecorder(), r, &msg, 1024)
+			var reqErr *requestError
+			if !errors.As(err, &reqErr) {
+				t.Fatalf("error = %v, want a *requestError", err)
+			}
+			if reqErr.status != http.StatusBadRequest || reqErr.msg != tt.want {
+				t.Errorf("error = %d %q, want 400 %q", reqErr.status, reqErr.msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoContentType(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+	}{
+		{"json", "application/json", http.StatusOK},
+		{"json with charset", "application/json; charset=utf-8", http.StatusOK},
+		{"missing", "", http.StatusOK},
+		{"form", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text", "text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/echo", strings.NewReader(`{"text":"typed"}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusUnsupportedMediaType {
+				return
+			}
+			want := `Content-Type "` + tt.contentType + `" is not supported, use application/json`
+			if got := decode[errorResponse](t, rec); got.Error != want {
+				t.Errorf("error = %q, want %q", got.Error, want)
+			}
+		})
+	}
+}
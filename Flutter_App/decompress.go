@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompress transparently decodes request bodies sent with
+// Content-Encoding gzip or deflate. Handlers see the decoded body, so the
+// size limits they apply count decompressed bytes and a small compressed
+// body cannot expand past them. A body that does not decode is a 400, one
+// that runs into a size limit while being read is a 413, and an encoding the
+// server does not know is a 415.
+func decompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var (
+			zr  io.ReadCloser
+			err error
+		)
+		switch encoding {
+		case "gzip", "x-gzip":
+			zr, err = gzip.NewReader(r.Body)
+		case "deflate":
+			zr, err = zlib.NewReader(r.Body)
+		default:
+			writeJSONError(w, http.StatusUnsupportedMediaType,
+				fmt.Sprintf("Content-Encoding %q is not supported, use gzip or deflate", encoding))
+			return
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("body is not valid %s data", encoding))
+			return
+		}
+		defer zr.Close()
+
+		r.Body = &decompressedBody{zr: zr, body: r.Body, encoding: encoding}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decompressedBody reads through zr and closes both it and the original
+// body. Decoding errors are reported as client errors so handlers return a
+// 400 rather than a 500; see decodingError.
+type decompressedBody struct {
+	zr       io.ReadCloser
+	body     io.ReadCloser
+	encoding string
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	n, err := b.zr.Read(p)
+	if err != nil && err != io.EOF {
+		err = decodingError(err, b.encoding)
+	}
+	return n, err
+}
+
+// decodingError reports a failure to read a compressed body. A size limit
+// hit while reading the compressed bytes is passed through as is, so it is
+// still answered with 413; anything else means the data is corrupt.
+func decodingError(err error, encoding string) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return badRequest("body is not valid %s data", encoding)
+}
+
+func (b *decompressedBody) Close() error {
+	err := b.zr.Close()
+	if cerr := b.body.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
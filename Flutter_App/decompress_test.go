@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compressed encodes body with the named Content-Encoding.
+func compressed(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	switch encoding {
+	case "gzip":
+		zw = gzip.NewWriter(&buf)
+	case "deflate":
+		zw = zlib.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+	io.WriteString(zw, body)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressRequestBody(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBodyBytes = 1024
+	h := newTestServer(t, cfg).handler(slog.Default())
+	post := func(encoding string, body []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Encoding", encoding)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			rec := post(encoding, compressed(t, encoding, `{"text":"squeezed"}`))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if got := decode[Message](t, rec); got.Text != "squeezed" {
+				t.Errorf("echoed %q, want the decompressed text", got.Text)
+			}
+		})
+	}
+
+	t.Run("corrupt", func(t *testing.T) {
+		rec := post("gzip", []byte(`{"text":"not gzip"}`))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "body is not valid gzip data" {
+			t.Errorf("error = %q", got.Error)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		if rec := post("br", []byte("whatever")); rec.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("status = %d, want 415: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("expands past the limit", func(t *testing.T) {
+		body := compressed(t, "gzip", `{"text":"`+strings.Repeat("a", 4096)+`"}`)
+		if len(body) >= int(cfg.MaxBodyBytes) {
+			t.Fatalf("compressed body is %d bytes, want it under the limit", len(body))
+		}
+		if rec := post("gzip", body); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestDecompressKeepsSizeLimitErrors(t *testing.T) {
+	// A limit on the compressed bytes, such as one applied in front of
+	// decompress, is a 413 even though it surfaces through the decoder.
+	h := decompress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if err := decodeJSONBody(w, r, &msg, 1<<20); err != nil {
+			writeRequestError(w, err)
+		}
+	}))
+	body := compressed(t, "gzip", `{"text":"`+strings.Repeat("a", 4096)+`"}`)
+	for name, limit := range map[string]int64{"in the header": 4, "in the stream": int64(len(body)) - 8} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+			r.Header.Set("Content-Encoding", "gzip")
+			r.Body = http.MaxBytesReader(rec, r.Body, limit)
+			h.ServeHTTP(rec, r)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// newETagEpoch returns a random per-process value mixed into ETags, so the
+// write counter starting again from zero after a restart cannot reproduce
+// an ETag for different data.
+func newETagEpoch() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// messagesETag returns a weak ETag for one page of the message listing. It
+// changes whenever a write bumps s.writes, and when a message expires,
+// since nextExpiry then moves on.
+func (s *server) messagesETag(limit, offset int, nextExpiry time.Time) string {
+	key := fmt.Sprintf("%s/%d/%d/%d/%d", s.etagEpoch, s.writes.Load(), nextExpiry.UnixNano(), limit, offset)
+	sum := sha256.Sum256([]byte(key))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+// under the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// setValidators marks a response as cacheable only after revalidation with
+// etag.
+func setValidators(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+}
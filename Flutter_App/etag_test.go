@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestListMessagesETag(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	postMessage(t, h, "first")
+
+	rec := do(t, h, "GET", "/messages", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET /messages = %d with ETag %q, want 200 and an ETag", rec.Code, etag)
+	}
+
+	rec = do(t, h, "GET", "/messages", "", "If-None-Match", etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("revalidating = %d with %d body bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Errorf("304 ETag = %q, want %q", got, etag)
+	}
+
+	postMessage(t, h, "second")
+	rec = do(t, h, "GET", "/messages", "", "If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revalidating after a post = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got == etag || got == "" {
+		t.Errorf("ETag after a post = %q, want a new one", got)
+	}
+	if page := decode[messagePage](t, rec); page.Total != 2 {
+		t.Errorf("total = %d, want 2", page.Total)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"other", W/"abc"`, true},
+		{"*", true},
+		{`W/"abcd"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `W/"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is how often a comment line is sent on an idle event stream
+// so proxies do not time the connection out.
+const sseKeepAlive = 15 * time.Second
+
+// events streams each newly posted message as a server-sent event until the
+// client disconnects. When the server shuts down the stream ends with an
+// "event: shutdown" message; a client evicted for falling behind gets
+// "event: evicted" instead.
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// The stream outlives the server's WriteTimeout, so each write gets a
+	// deadline of its own instead. A client that stops reading then fails
+	// the next write and ends the stream, rather than pinning this handler
+	// on a full connection after the hub has evicted it.
+	rc := http.NewResponseController(w)
+	send := func(format string, args ...any) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout)); err != nil {
+			slog.DebugContext(r.Context(), "setting write deadline for event stream", "error", err)
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := send(""); err != nil {
+		return
+	}
+
+	sub := s.hub.subscribe(r.RemoteAddr, nil)
+	defer s.hub.unsubscribe(sub)
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.send:
+			if !ok {
+				event := "shutdown"
+				if sub.evicted {
+					event = "evicted"
+				}
+				send("event: %s\ndata: {}\n\n", event)
+				return
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "encoding event", "id", msg.ID, "error", err)
+				continue
+			}
+			if err := send("data: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := send(": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one server-sent event read from a stream.
+type sseEvent struct {
+	name, data string
+}
+
+// openEvents subscribes to the /events stream of the server at baseURL.
+func openEvents(t *testing.T, baseURL string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if ct := res.Header.Get("Content-Type"); res.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("GET /events = %d %q, want a 200 event stream", res.StatusCode, ct)
+	}
+	return bufio.NewReader(res.Body)
+}
+
+// readEvent reads the next event from r, skipping keep-alive comments.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.data != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEventsStreamsPostedMessages(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	ts := serve(t, s)
+
+	stream := openEvents(t, ts.URL)
+	waitFor(t, "the stream to subscribe", func() bool { return s.hub.count() == 1 })
+	res, err := http.Post(ts.URL+"/echo", "application/json", strings.NewReader(`{"text":"streamed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	ev := readEvent(t, stream)
+	if ev.name != "" {
+		t.Errorf("event name = %q, want a default message event", ev.name)
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(ev.data), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "streamed" {
+		t.Errorf("streamed message = %+v, want the posted one", msg)
+	}
+}
+
+func TestEventsEndsStreamOfEvictedClient(t *testing.T) {
+	cfg := testConfig()
+	cfg.WriteTimeout = 200 * time.Millisecond
+	s := newTestServer(t, cfg)
+	h := s.handler(slog.Default())
+	returned := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		if r.URL.Path == "/events" {
+			close(returned)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	// Subscribe, then never read, so the connection's buffers fill and the
+	// handler's writes block.
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintf(conn, "GET /events HTTP/1.1\r\nHost: %s\r\n\r\n", ts.Listener.Addr())
+	waitFor(t, "the stream to subscribe", func() bool { return s.hub.count() == 1 })
+
+	big := strings.Repeat("x", 64<<10)
+	deadline := time.Now().Add(10 * time.Second)
+	for s.hub.evictions.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stalled client was never evicted")
+		}
+		s.hub.publish(Message{Text: big})
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event stream handler still blocked writing to an evicted client")
+	}
+}
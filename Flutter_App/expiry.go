@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// newMessage returns a message with the given text received now, expiring
+// after ttl unless ttl is zero.
+func newMessage(text string, ttl time.Duration) Message {
+	msg := Message{Text: text, ReceivedAt: time.Now().UTC()}
+	if ttl > 0 {
+		msg.ExpiresAt = msg.ReceivedAt.Add(ttl)
+	}
+	return msg
+}
+
+// parseTTL reads the ttl_seconds query parameter, falling back to def when
+// it is absent.
+func parseTTL(v string, def time.Duration) (time.Duration, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/int64(time.Second) {
+		return 0, errors.New("ttl_seconds must be a positive integer")
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// purgeExpired deletes expired messages from the store every
+// cfg.ExpiryInterval until ctx is cancelled. Reads already skip expired
+// messages; purging reclaims their space and corrects Count.
+func (s *server) purgeExpired(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			storeCtx, cancel := context.WithTimeout(ctx, s.cfg.StoreTimeout)
+			n, err := s.store.DeleteExpired(storeCtx, now)
+			cancel()
+			switch {
+			case errors.Is(err, errStoreUnavailable):
+				// Degraded mode is already reported by /health.
+			case err != nil:
+				slog.Warn("purging expired messages", "error", err)
+			case n > 0:
+				// The list ETags already changed when these expired.
+				slog.Info("purged expired messages", "count", n)
+			}
+		}
+	}
+}
+
+// expiryMark caches when the next stored message expires, so the list
+// ETags can change at that moment without asking the store on every
+// request.
+type expiryMark struct {
+	mu    sync.Mutex
+	known bool
+	next  time.Time // zero when no stored message expires
+}
+
+// nextExpiry returns when the next stored message expires, or the zero time
+// if none does. The cached value is refreshed from the store once it has
+// passed.
+func (s *server) nextExpiry(ctx context.Context) (time.Time, error) {
+	m := &s.expiry
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.known && (m.next.IsZero() || now.Before(m.next)) {
+		return m.next, nil
+	}
+	next, err := s.store.NextExpiry(ctx, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	m.next, m.known = next, true
+	return next, nil
+}
+
+// noteExpiry records the expiry of a message that has just been stored.
+func (s *server) noteExpiry(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	m := &s.expiry
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.known && (m.next.IsZero() || at.Before(m.next)) {
+		m.next = at
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+	if d, err := parseTTL("", time.Minute); err != nil || d != time.Minute {
+		t.Errorf("parseTTL(\"\") = %s, %v, want the default", d, err)
+	}
+	if d, err := parseTTL("90", time.Minute); err != nil || d != 90*time.Second {
+		t.Errorf("parseTTL(\"90\") = %s, %v, want 90s", d, err)
+	}
+	for _, v := range []string{"0", "-5", "1.5", "soon", "9223372036854775807"} {
+		if _, err := parseTTL(v, 0); err == nil {
+			t.Errorf("parseTTL(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestExpiredMessagesDisappear(t *testing.T) {
+	cfg := testConfig()
+	cfg.MessageTTL = 100 * time.Millisecond
+	cfg.ExpiryInterval = 20 * time.Millisecond
+	s := newTestServer(t, cfg)
+	h := s.handler(slog.Default())
+
+	short := postMessage(t, h, "fleeting")
+	if short.ExpiresAt.IsZero() {
+		t.Fatalf("message %+v has no expiry, want the default TTL", short)
+	}
+	rec := do(t, h, "POST", "/echo?ttl_seconds=3600", `{"text":"lasting"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST with ttl_seconds = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(t, h, "GET", "/messages", "")
+	etag := rec.Header().Get("ETag")
+	if page := decode[messagePage](t, rec); page.Total != 2 {
+		t.Fatalf("total before expiry = %d, want 2", page.Total)
+	}
+
+	time.Sleep(time.Until(short.ExpiresAt) + 10*time.Millisecond)
+	// The listing changes the moment the message expires, before any purge.
+	rec = do(t, h, "GET", "/messages", "", "If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revalidating after expiry = %d, want 200 with the new listing", rec.Code)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after a message expired")
+	}
+	page := decode[messagePage](t, rec)
+	if page.Total != 1 || len(page.Messages) != 1 || page.Messages[0].Text != "lasting" {
+		t.Errorf("listing after expiry = %+v, want only the lasting message", page)
+	}
+	if rec := do(t, h, "GET", "/messages/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of the expired message = %d, want 404", rec.Code)
+	}
+
+	go s.purgeExpired(t.Context())
+	waitFor(t, "the expired message to be purged", func() bool {
+		n, _ := s.store.Count(t.Context())
+		return n == 1
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// redirect sends the client to path on this server. Behind a trusted proxy
+// the Location is absolute, built from X-Forwarded-Proto and
+// X-Forwarded-Host so that a client that reached a TLS-terminating proxy
+// over HTTPS stays on HTTPS and on the proxy's public host. Otherwise the
+// headers are ignored, since any client can send them, and the Location is
+// left relative for the client to resolve against the URL it used.
+func (s *server) redirect(w http.ResponseWriter, r *http.Request, path string, code int) {
+	if s.cfg.TrustProxy {
+		path = externalOrigin(r) + path
+	}
+	http.Redirect(w, r, path, code)
+}
+
+// externalOrigin returns the scheme and host the client used to reach the
+// proxy in front of this server, falling back to those of r itself for
+// whichever forwarded header is missing or malformed.
+func externalOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(lastForwarded(r.Header, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if fh := lastForwarded(r.Header, "X-Forwarded-Host"); fh != "" && !strings.ContainsAny(fh, "/\\@ ") {
+		host = fh
+	}
+	return scheme + "://" + host
+}
+
+// lastForwarded returns the last entry of the comma-separated forwarded
+// header name, across every line of it in h. That entry was added by the
+// trusted proxy in front of this server; the ones before it come from
+// further away and may have been made up by the client, as proxies append
+// to these headers rather than replace them.
+func lastForwarded(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	return strings.TrimSpace(v[strings.LastIndexByte(v, ',')+1:])
+}
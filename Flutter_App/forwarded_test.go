@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHonoursTrustedProxy(t *testing.T) {
+	forwarded := []string{
+		"X-Forwarded-Proto", "https",
+		"X-Forwarded-Host", "app.example",
+	}
+	tests := []struct {
+		name       string
+		trustProxy bool
+		target     string
+		header     []string
+		want       string
+	}{
+		{"untrusted slash redirect", false, "/messages/", forwarded, "/messages"},
+		{"trusted slash redirect", true, "/messages/", forwarded, "https://app.example/messages"},
+		{"trusted base path redirect", true, "/api", forwarded, "https://app.example/api/"},
+		{"trusted without headers", true, "/messages/", nil, "http://example.com/messages"},
+		{"trusted with an invalid scheme", true, "/messages/", []string{"X-Forwarded-Proto", "gopher"}, "http://example.com/messages"},
+		{"trusted with a spoofed entry", true, "/messages/", []string{"X-Forwarded-Proto", "http, https"}, "https://example.com/messages"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.TrustProxy = tt.trustProxy
+			if tt.target == "/api" {
+				cfg.BasePath = "/api"
+			}
+			h := newTestServer(t, cfg).handler(slog.Default())
+
+			rec := do(t, h, "GET", tt.target, "", tt.header...)
+			if rec.Code != http.StatusMovedPermanently {
+				t.Fatalf("status = %d, want 301", rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastForwarded(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("X-Forwarded-Host", "spoofed.example, first.example")
+	r.Header.Add("X-Forwarded-Host", " proxy.example ")
+	if got := lastForwarded(r.Header, "X-Forwarded-Host"); got != "proxy.example" {
+		t.Errorf("lastForwarded = %q, want the entry from the nearest proxy", got)
+	}
+	if got := lastForwarded(r.Header, "X-Forwarded-Proto"); got != "" {
+		t.Errorf("lastForwarded of a missing header = %q, want empty", got)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultLanguage is used when the client asks for nothing we support.
+const defaultLanguage = "en"
+
+// greetings holds the greeting served by GET / in each supported language,
+// keyed by primary language subtag.
+var greetings = map[string]string{
+	"en": "Hello from Go!",
+	"es": "¡Hola desde Go!",
+	"fr": "Bonjour de la part de Go !",
+	"de": "Hallo von Go!",
+	"ja": "Goからこんにちは！",
+}
+
+// greetingLanguages returns the supported languages in sorted order.
+func greetingLanguages() []string {
+	langs := make([]string, 0, len(greetings))
+	for lang := range greetings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	lang := greetingLanguage(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	respond(w, r, Message{Text: greetings[lang]})
+}
+
+// greetingLanguage picks the greeting language: the lang query parameter if
+// it names a supported language, else the best match for Accept-Language,
+// else English.
+func greetingLanguage(r *http.Request) string {
+	if lang := primarySubtag(r.URL.Query().Get("lang")); greetings[lang] != "" {
+		return lang
+	}
+	return negotiateLanguage(r.Header.Get("Accept-Language"))
+}
+
+// negotiateLanguage returns the supported language with the highest q-value
+// in an Accept-Language header. Tags are matched on their primary subtag,
+// so "es-MX" selects "es"; earlier tags win ties.
+func negotiateLanguage(header string) string {
+	best, bestQ := defaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		lang := primarySubtag(tag)
+		if lang == "*" {
+			lang = defaultLanguage
+		}
+		if greetings[lang] != "" && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// primarySubtag returns the lower-cased language part of a tag like
+// "pt-BR".
+func primarySubtag(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(lang)
+}
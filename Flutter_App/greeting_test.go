@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHelloLanguage(t *testing.T) {
+	tests := []struct {
+		name, target, acceptLanguage string
+		want                         string
+	}{
+		{"exact match", "/", "fr", "fr"},
+		{"region subtag", "/", "es-MX", "es"},
+		{"quality order", "/", "de;q=0.5, ja;q=0.9, fr;q=0.7", "ja"},
+		{"unsupported preferred", "/", "pt-BR, de;q=0.8", "de"},
+		{"query override", "/?lang=ja", "fr", "ja"},
+		{"unsupported query", "/?lang=xx", "de", "de"},
+		{"unsupported only", "/", "pt, zh;q=0.5", "en"},
+		{"no header", "/", "", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.acceptLanguage != "" {
+				header = []string{"Accept-Language", tt.acceptLanguage}
+			}
+			rec := do(t, http.HandlerFunc(hello), "GET", tt.target, "", header...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Language"); got != tt.want {
+				t.Errorf("Content-Language = %q, want %q", got, tt.want)
+			}
+			if got := decode[Message](t, rec); got.Text != greetings[tt.want] {
+				t.Errorf("greeting = %q, want %q", got.Text, greetings[tt.want])
+			}
+			if rec.Header().Get("Vary") != "Accept-Language" {
+				t.Errorf("Vary = %q, want Accept-Language", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// compress gzips responses for clients that accept it. Responses smaller
+// than minSize bytes are sent as-is since the gzip overhead outweighs the
+// saving.
+func compress(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the first minSize bytes of the response.
+// If the response grows past that it switches to gzip; otherwise the
+// buffered bytes are written uncompressed when the handler finishes.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *gzipResponseWriter) writeHeader() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *gzipResponseWriter) startGzip() error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		// The handler already encoded the body itself.
+		return w.startPlain()
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.writeHeader()
+
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *gzipResponseWriter) startPlain() error {
+	w.decided = true
+	w.writeHeader()
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Flush commits the response. A response flushed before reaching minSize is
+// sent uncompressed, which keeps streams such as server-sent events working.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.startPlain()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes any buffered bytes and finishes the gzip stream.
+func (w *gzipResponseWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return nil
+		}
+		return w.startPlain()
+	}
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	gzipWriters.Put(w.gz)
+	w.gz = nil
+	return err
+}
+
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	const minSize = 1024
+	large := strings.Repeat("compress me please ", 200)
+	h := compress(minSize, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/small" {
+			io.WriteString(w, "tiny")
+			return
+		}
+		io.WriteString(w, large)
+	}))
+
+	t.Run("gzipped when accepted", func(t *testing.T) {
+		rec := do(t, h, "GET", "/large", "", "Accept-Encoding", "gzip")
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != large {
+			t.Errorf("decompressed body differs from the uncompressed one (%d bytes, want %d)", len(body), len(large))
+		}
+	})
+
+	t.Run("identity when not accepted", func(t *testing.T) {
+		rec := do(t, h, "GET", "/large", "")
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("Content-Encoding = %q, want none", got)
+		}
+		if rec.Body.String() != large {
+			t.Error("body differs from the handler's output")
+		}
+	})
+
+	t.Run("small responses left alone", func(t *testing.T) {
+		rec := do(t, h, "GET", "/small", "", "Accept-Encoding", "gzip")
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("Content-Encoding = %q, want none below %d bytes", got, minSize)
+		}
+		if rec.Body.String() != "tiny" {
+			t.Errorf("body = %q, want tiny", rec.Body)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// startTime records when the server started and is set in main.
+var startTime time.Time
+
+// healthCheck reports a non-nil error when the dependency it probes is
+// unhealthy.
+type healthCheck func(ctx context.Context) error
+
+// healthRegistry holds the named checks run on every /health request.
+type healthRegistry struct {
+	mu     sync.RWMutex
+	checks map[string]healthCheck
+}
+
+// degradedError marks a check failure as non-fatal: the server is still
+// useful, so /health reports "degraded" with a 200 instead of failing the
+// probe and getting the instance restarted.
+type degradedError struct{ err error }
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{checks: make(map[string]healthCheck)}
+}
+
+// register adds or replaces the check with the given name.
+func (h *healthRegistry) register(name string, check healthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+type checkFailure struct {
+	Name     string `json:"name"`
+	Error    string `json:"error"`
+	degraded bool
+}
+
+// run executes every registered check and returns the failures sorted by
+// name.
+func (h *healthRegistry) run(ctx context.Context) []checkFailure {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var failures []checkFailure
+	for name, check := range h.checks {
+		if err := check(ctx); err != nil {
+			var d degradedError
+			failures = append(failures, checkFailure{Name: name, Error: err.Error(), degraded: errors.As(err, &d)})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Name < failures[j].Name })
+	return failures
+}
+
+type readyResponse struct {
+	Status string `json:"status"`
+}
+
+// ready is the readiness probe for load balancers. Unlike health it does not
+// look at dependencies: it fails as soon as a shutdown begins, so traffic is
+// moved elsewhere while in-flight requests finish.
+func (s *server) ready(w http.ResponseWriter, r *http.Request) {
+	if s.stopping.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "draining"})
+		return
+	}
+	writeJSON(w, http.StatusOK, readyResponse{Status: "ready"})
+}
+
+type healthResponse struct {
+	Status        string         `json:"status"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	FailingChecks []checkFailure `json:"failing_checks,omitempty"`
+}
+
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{
+		Status:        "ok",
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	failures := s.checks.run(r.Context())
+	resp.FailingChecks = failures
+	for _, f := range failures {
+		if !f.degraded {
+			resp.Status = "unavailable"
+			writeJSON(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+	}
+	if len(failures) > 0 {
+		resp.Status = "degraded"
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
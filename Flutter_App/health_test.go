@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  healthCheck
+		status int
+		want   string
+	}{
+		{"healthy", func(context.Context) error { return nil }, http.StatusOK, "ok"},
+		{"unhealthy", func(context.Context) error { return errors.New("disk on fire") }, http.StatusServiceUnavailable, "unavailable"},
+		{"degraded", func(context.Context) error { return degradedError{errors.New("cache cold")} }, http.StatusOK, "degraded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, defaultConfig())
+			s.checks.register("probe", tt.check)
+
+			rec := do(t, s.handler(slog.Default()), "GET", "/health", "")
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			got := decode[healthResponse](t, rec)
+			if got.Status != tt.want {
+				t.Errorf("status field = %q, want %q", got.Status, tt.want)
+			}
+			if tt.want == "ok" {
+				if len(got.FailingChecks) != 0 {
+					t.Errorf("failing_checks = %v, want none", got.FailingChecks)
+				}
+			} else if len(got.FailingChecks) != 1 || got.FailingChecks[0].Name != "probe" {
+				t.Errorf("failing_checks = %v, want the probe check", got.FailingChecks)
+			}
+		})
+	}
+}
+
+func TestReadyFailsWhileDraining(t *testing.T) {
+	cfg := testConfig()
+	cfg.DrainDelay = 300 * time.Millisecond
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newTestServer(t, cfg)
+	srv := newHTTPServer(cfg, app.handler(slog.Default()))
+	done := make(chan struct{})
+	go func() {
+		waitForShutdown(srv, app, cfg.ShutdownTimeout)
+		close(done)
+	}()
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	base := "http://" + ln.Addr().String()
+	client := closingClient()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := client.Get(base + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer res.Body.Close()
+		var body readyResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		return res.StatusCode, body.Status
+	}
+	if code, status := get("/ready"); code != http.StatusOK || status != "ready" {
+		t.Fatalf("GET /ready = %d %q, want 200 ready", code, status)
+	}
+
+	app.requestShutdown()
+	// During the drain delay the server still serves, but reports not ready.
+	if code, status := get("/ready"); code != http.StatusServiceUnavailable || status != "draining" {
+		t.Errorf("GET /ready while draining = %d %q, want 503 draining", code, status)
+	}
+	if code, _ := get("/health"); code != http.StatusOK {
+		t.Errorf("GET /health while draining = %d, want 200", code)
+	}
+	select {
+	case <-done:
+		t.Fatal("server stopped before the drain delay ran out")
+	default:
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after the drain delay")
+	}
+}
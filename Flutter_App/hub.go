@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait bounds a single write to a WebSocket peer.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long we wait for a pong before giving up on a peer.
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait.
+	wsPingPeriod = wsPongWait * 9 / 10
+	// subscriberBuffer is the number of messages queued per client; a client
+	// that falls further behind is disconnected.
+	subscriberBuffer = 16
+	// streamDrainGrace is how long streaming clients get to receive the
+	// shutdown notice and disconnect before their connections are closed.
+	streamDrainGrace = 5 * time.Second
+)
+
+// hub fans out newly posted messages to every streaming subscriber
+// (WebSocket or server-sent events). Each subscriber has its own buffered
+// channel drained by its own goroutine. A client whose buffer is full when
+// a message arrives is evicted, so a wedged client can neither stall the
+// broadcast nor pin its buffer indefinitely.
+type hub struct {
+	mu        sync.Mutex
+	clients   map[*subscriber]struct{}
+	broadcast chan Message
+	// closed is set by drain; drained is closed once the last subscriber
+	// has unsubscribed after that.
+	closed  bool
+	drained chan struct{}
+	// evictions counts clients dropped for falling behind.
+	evictions atomic.Uint64
+}
+
+// subscriber is one streaming client registered with the hub. A closed send
+// channel tells the client's handler to end the stream.
+type subscriber struct {
+	remoteAddr string
+	send       chan Message
+	// conn, if set, is closed forcibly when the client outstays the drain.
+	conn io.Closer
+	// ended records that send has been closed, and evicted that it was
+	// closed because the client fell behind. Both are guarded by hub.mu;
+	// evicted may also be read by the client's handler once it has seen
+	// send closed.
+	ended   bool
+	evicted bool
+}
+
+type wsClient struct {
+	conn *websocket.Conn
+	sub  *subscriber
+}
+
+func newHub() *hub {
+	return &hub{
+		clients:   make(map[*subscriber]struct{}),
+		broadcast: make(chan Message, 256),
+		drained:   make(chan struct{}),
+	}
+}
+
+// run delivers broadcast messages to clients until ctx is cancelled.
+func (h *hub) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-h.broadcast:
+			h.mu.Lock()
+			for c := range h.clients {
+				if c.ended {
+					continue
+				}
+				select {
+				case c.send <- msg:
+				default:
+					slog.Warn("streaming client too slow, disconnecting", "remote_addr", c.remoteAddr, "id", msg.ID)
+					h.evict(c)
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// publish queues msg for broadcast without blocking the caller.
+func (h *hub) publish(msg Message) {
+	select {
+	case h.broadcast <- msg:
+	default:
+		slog.Warn("broadcast queue full, dropping message", "id", msg.ID)
+	}
+}
+
+// subscribe registers a new subscriber for the client at remoteAddr. conn
+// may be nil for streams the http.Server closes itself. Once the hub is
+// draining, the returned subscriber's send channel is already closed.
+func (h *hub) subscribe(remoteAddr string, conn io.Closer) *subscriber {
+	c := &subscriber{remoteAddr: remoteAddr, send: make(chan Message, subscriberBuffer), conn: conn}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		c.end()
+		return c
+	}
+	h.clients[c] = struct{}{}
+	return c
+}
+
+// unsubscribe removes c and closes its send channel. It is safe to call more
+// than once.
+func (h *hub) unsubscribe(c *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	c.end()
+	if h.closed && len(h.clients) == 0 {
+		close(h.drained)
+	}
+}
+
+// evict unregisters c and ends its stream, marking it so the handler can
+// tell the client it was dropped rather than that the server is stopping.
+// The caller must hold h.mu.
+func (h *hub) evict(c *subscriber) {
+	delete(h.clients, c)
+	c.evicted = true
+	c.end()
+	h.evictions.Add(1)
+	if h.closed && len(h.clients) == 0 {
+		close(h.drained)
+	}
+}
+
+// count returns the number of connected streaming clients.
+func (h *hub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// end closes c.send once. The caller must hold hub.mu.
+func (c *subscriber) end() {
+	if !c.ended {
+		c.ended = true
+		close(c.send)
+	}
+}
+
+// drain ends every stream and refuses new ones, then waits for the clients'
+// handlers to send their shutdown notice and unsubscribe. Connections still
+// open when ctx is done are closed forcibly and ctx's error is returned.
+func (h *hub) drain(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		for c := range h.clients {
+			c.end()
+		}
+		if len(h.clients) == 0 {
+			close(h.drained)
+		}
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if c.conn != nil {
+			slog.Warn("closing streaming client that did not disconnect", "remote_addr", c.remoteAddr)
+			c.conn.Close()
+		}
+	}
+	return ctx.Err()
+}
+
+// checkOrigin accepts requests without an Origin header, same-host origins
+// and origins on the CORS allowlist.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(allowed, origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+}
+
+func (s *server) websocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin(s.cfg.CORSOrigins)}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		slog.DebugContext(r.Context(), "websocket upgrade failed", "error", err)
+		return
+	}
+
+	c := &wsClient{conn: conn, sub: s.hub.subscribe(r.RemoteAddr, conn)}
+	go c.writePump()
+	go c.readPump(s.hub)
+}
+
+// readPump discards incoming frames but keeps reading so control frames are
+// processed and disconnects are noticed.
+func (c *wsClient) readPump(h *hub) {
+	defer func() {
+		h.unsubscribe(c.sub)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump sends queued messages and periodic pings until the send channel
+// is closed or a write fails.
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.sub.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// The peer is gone, it was evicted, or the server is
+				// draining; tell the client which in case it is listening.
+				closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				if c.sub.evicted {
+					closeMsg = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMsg)
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
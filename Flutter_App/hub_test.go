@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// dialWS opens a WebSocket to the /ws endpoint of the server at baseURL.
+func dialWS(t *testing.T, baseURL string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(baseURL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebSocketBroadcast(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	ts := serve(t, s)
+
+	clients := []*websocket.Conn{dialWS(t, ts.URL), dialWS(t, ts.URL)}
+	waitFor(t, "both clients to subscribe", func() bool { return s.hub.count() == 2 })
+
+	res, err := http.Post(ts.URL+"/echo", "application/json", strings.NewReader(`{"text":"hello, everyone"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST /echo = %d, want 200", res.StatusCode)
+	}
+
+	for i, conn := range clients {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("client %d: %v", i, err)
+		}
+		if msg.Text != "hello, everyone" || msg.ID != 1 {
+			t.Errorf("client %d got %+v, want message 1", i, msg)
+		}
+	}
+}
+
+func TestDrainNotifiesStreamingClients(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	ts := serve(t, s)
+
+	ws := dialWS(t, ts.URL)
+	events := openEvents(t, ts.URL)
+	waitFor(t, "both streams to subscribe", func() bool { return s.hub.count() == 2 })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := s.hub.drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if n := s.hub.count(); n != 0 {
+		t.Errorf("%d clients still subscribed after drain", n)
+	}
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("WebSocket read = %v, want a going-away close", err)
+	}
+	if ev := readEvent(t, events); ev.name != "shutdown" {
+		t.Errorf("event stream got %+v, want a shutdown event", ev)
+	}
+}
+
+func TestHubEvictsSlowClient(t *testing.T) {
+	h := newHub()
+	go h.run(t.Context())
+	slow := h.subscribe("slow", nil)
+	fast := h.subscribe("fast", nil)
+
+	// Publish one more message than the slow client can buffer, waiting
+	// for the fast client to take each so only the slow one falls behind.
+	const sent = subscriberBuffer + 1
+	for i := range sent {
+		h.publish(Message{ID: int64(i + 1)})
+		select {
+		case msg := <-fast.send:
+			if msg.ID != int64(i+1) {
+				t.Fatalf("fast client got message %d, want %d", msg.ID, i+1)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fast client did not receive message %d", i+1)
+		}
+	}
+
+	if n := h.count(); n != 1 {
+		t.Errorf("%d clients subscribed, want only the fast one left", n)
+	}
+	if n := h.evictions.Load(); n != 1 {
+		t.Errorf("evictions = %d, want 1", n)
+	}
+	// The slow client keeps what it buffered, then sees its stream end.
+	for i := range subscriberBuffer {
+		if msg, ok := <-slow.send; !ok || msg.ID != int64(i+1) {
+			t.Fatalf("slow client message %d = %+v (open %v), want message %d", i, msg, ok, i+1)
+		}
+	}
+	if _, ok := <-slow.send; ok {
+		t.Fatal("slow client's stream still open after its buffer overflowed")
+	}
+	h.mu.Lock()
+	evicted := slow.evicted
+	h.mu.Unlock()
+	if !evicted {
+		t.Error("slow client not marked as evicted")
+	}
+
+	h.publish(Message{ID: sent + 1})
+	select {
+	case msg := <-fast.send:
+		if msg.ID != sent+1 {
+			t.Errorf("fast client got message %d after the eviction, want %d", msg.ID, sent+1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fast client stopped receiving after the eviction")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLen bounds the keys clients may send.
+	maxIdempotencyKeyLen = 255
+	// idempotencySweepInterval is how often expired keys are evicted.
+	idempotencySweepInterval = time.Minute
+)
+
+// idempotencyCache remembers the response to each request carrying an
+// Idempotency-Key, so a client retrying after a lost response gets the
+// original result instead of creating a duplicate.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+	ttl     time.Duration
+	maxBody int64
+}
+
+type idempotentResponse struct {
+	// fingerprint identifies the request the key was first used with.
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	// done is false while the first request is still being handled.
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+}
+
+// newIdempotencyCache keeps responses for ttl. Request bodies are read up to
+// maxBody bytes to fingerprint them.
+func newIdempotencyCache(ttl time.Duration, maxBody int64) *idempotencyCache {
+	return &idempotencyCache{
+		entries: make(map[string]*idempotentResponse),
+		ttl:     ttl,
+		maxBody: maxBody,
+	}
+}
+
+// wrap makes next idempotent for requests with an Idempotency-Key header.
+// A repeat of the same request is answered from the cache; reusing the key
+// for a different request, or while the first is in flight, is a 409.
+// Server errors are not cached so the client can retry them. Keys are scoped
+// to the bearer token so clients cannot replay each other's responses.
+func (c *idempotencyCache) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeJSONError(w, http.StatusBadRequest,
+				fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.maxBody))
+		if err != nil {
+			writeRequestError(w, translateDecodeError(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		token, _ := bearerToken(r)
+		scoped := sha256.Sum256([]byte(token + "\x00" + key))
+		id := hex.EncodeToString(scoped[:])
+		fp := requestFingerprint(r, body)
+
+		c.mu.Lock()
+		e, ok := c.entries[id]
+		if ok && time.Now().After(e.expires) {
+			delete(c.entries, id)
+			ok = false
+		}
+		if ok {
+			replay := *e
+			c.mu.Unlock()
+			switch {
+			case replay.fingerprint != fp:
+				writeJSONError(w, http.StatusConflict,
+					idempotencyKeyHeader+" was already used with a different request")
+			case !replay.done:
+				writeJSONError(w, http.StatusConflict,
+					"A request with this "+idempotencyKeyHeader+" is still in progress")
+			default:
+				w.Header().Set("Content-Type", replay.contentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(replay.status)
+				w.Write(replay.body)
+			}
+			return
+		}
+		e = &idempotentResponse{fingerprint: fp, expires: time.Now().Add(c.ttl)}
+		c.entries[id] = e
+		c.mu.Unlock()
+
+		rec := &capturingWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if !completed || rec.status >= http.StatusInternalServerError {
+				delete(c.entries, id)
+				return
+			}
+			e.done = true
+			e.status = rec.status
+			e.contentType = rec.Header().Get("Content-Type")
+			e.body = rec.body.Bytes()
+		}()
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		completed = true
+	}
+}
+
+// requestFingerprint hashes the parts of r that determine its result.
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	h.Write(body)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// sweep periodically evicts expired keys until ctx is cancelled.
+func (c *idempotencyCache) sweep(ctx context.Context) {
+	ticker := time.NewTicker(idempotencySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for id, e := range c.entries {
+				if now.After(e.expires) {
+					delete(c.entries, id)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// capturingWriter passes a response through while keeping a copy of its
+// status and body.
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *capturingWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *capturingWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *capturingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = 50 * time.Millisecond
+	s := newTestServer(t, cfg)
+	h := s.handler(slog.Default())
+	post := func(key, body string) (Message, *http.Response) {
+		t.Helper()
+		rec := do(t, h, "POST", "/echo", body, idempotencyKeyHeader, key)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /echo with key %q = %d: %s", key, rec.Code, rec.Body)
+		}
+		return decode[Message](t, rec), rec.Result()
+	}
+
+	first, _ := post("order-1", `{"text":"once"}`)
+	t.Run("duplicate", func(t *testing.T) {
+		again, res := post("order-1", `{"text":"once"}`)
+		if again != first || res.Header.Get("Idempotent-Replayed") != "true" {
+			t.Errorf("retry = %+v (replayed %q), want the original %+v replayed", again, res.Header.Get("Idempotent-Replayed"), first)
+		}
+		if n, _ := s.store.Count(t.Context()); n != 1 {
+			t.Errorf("store holds %d messages, want the retry not stored again", n)
+		}
+	})
+
+	t.Run("conflicting body", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo", `{"text":"twice"}`, idempotencyKeyHeader, "order-1")
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "Idempotency-Key was already used with a different request" {
+			t.Errorf("error = %q", got.Error)
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		time.Sleep(2 * cfg.IdempotencyTTL)
+		fresh, res := post("order-1", `{"text":"once"}`)
+		if fresh.ID == first.ID || res.Header.Get("Idempotent-Replayed") != "" {
+			t.Errorf("after expiry got %+v (replayed %q), want a newly stored message", fresh, res.Header.Get("Idempotent-Replayed"))
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+type Message struct {
+	ID         int64     `json:"id,omitempty"`
+	Text       string    `json:"text"`
+	ReceivedAt time.Time `json:"received_at,omitzero"`
+	// ExpiresAt is when the message is deleted; zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func (m Message) plainText() string {
+	return m.Text
+}
+
+// expired reports whether m has a TTL that has run out by now.
+func (m Message) expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// echoResponse is the response to POST /echo: the message, plus dry_run
+// when it was not stored and meta when the client asked for it. Without
+// either parameter it has the same shape as Message.
+type echoResponse struct {
+	Message
+	DryRun bool         `json:"dry_run,omitempty"`
+	Meta   *messageMeta `json:"meta,omitempty"`
+}
+
+// validateMessage rejects messages with empty text or text longer than
+// maxLen runes. Length is counted in runes so multibyte input is measured
+// the way users see it. Problems are returned as a *validationError.
+func validateMessage(msg Message, maxLen int) error {
+	var v validationError
+	if msg.Text == "" {
+		v.add("text", "must not be empty")
+	}
+	if n := utf8.RuneCountInString(msg.Text); n > maxLen {
+		v.add("text", "is %d characters, maximum is %d", n, maxLen)
+	}
+	return v.err()
+}
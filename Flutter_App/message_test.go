@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEchoValidation(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxMessageLength = 10
+	h := newTestServer(t, cfg).handler(slog.Default())
+
+	tests := []struct {
+		name   string
+		text   string
+		status int
+	}{
+		{"empty", "", http.StatusUnprocessableEntity},
+		{"over length", strings.Repeat("a", 11), http.StatusUnprocessableEntity},
+		{"multibyte at the limit", strings.Repeat("é", 10), http.StatusOK},
+		{"valid", "hello", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, "POST", "/echo", `{"text":"`+tt.text+`"}`)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK {
+				if got := decode[Message](t, rec); got.Text != tt.text {
+					t.Errorf("echoed text = %q, want %q", got.Text, tt.text)
+				}
+			}
+		})
+	}
+}
+
+func TestEchoReportsEveryValidationError(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	rec := do(t, h, "POST", "/echo", `{"txt":"typo","lang":"en"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+	got := decode[validationResponse](t, rec)
+	if got.Error != "Validation failed" || got.Status != http.StatusUnprocessableEntity {
+		t.Errorf("body = %+v, want the 422 envelope", got)
+	}
+	want := map[string]string{
+		"/text": "is required",
+		"/txt":  "is not allowed",
+		"/lang": "is not allowed",
+	}
+	if len(got.Errors) != len(want) {
+		t.Fatalf("errors = %+v, want one per problem: %v", got.Errors, want)
+	}
+	for _, fe := range got.Errors {
+		if msg, ok := want[fe.Pointer]; !ok || fe.Message != msg || "/"+fe.Field != fe.Pointer {
+			t.Errorf("unexpected error %+v", fe)
+		}
+	}
+}
+
+func TestEchoBatchReportsErrorsByIndex(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	rec := do(t, h, "POST", "/echo/batch", `[{"text":""},{"text":"fine"},{"text":"ok","extra":1}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	results := decode[[]batchResult](t, rec)
+	if len(results) != 3 || results[1].Status != "ok" {
+		t.Fatalf("results = %+v, want item 1 stored", results)
+	}
+	for _, want := range []struct {
+		index          int
+		field, message string
+	}{
+		{0, "text", "must not be empty"},
+		{2, "extra", "is not allowed"},
+	} {
+		r := results[want.index]
+		if r.Status != "error" || len(r.Errors) != 1 {
+			t.Errorf("item %d = %+v, want one error", want.index, r)
+			continue
+		}
+		fe := r.Errors[0]
+		if fe.Index == nil || *fe.Index != want.index || fe.Field != want.field || fe.Message != want.message {
+			t.Errorf("item %d error = %+v, want index %d, field %s: %s", want.index, fe, want.index, want.field, want.message)
+		}
+	}
+}
+
+func TestEchoMessageCountHeader(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for i := 1; i <= 3; i++ {
+		rec := do(t, h, "POST", "/echo", `{"text":"count me"}`)
+		if got, want := rec.Header().Get("X-Message-Count"), strconv.Itoa(i); got != want {
+			t.Errorf("X-Message-Count after %d posts = %q, want %s", i, got, want)
+		}
+	}
+
+	do(t, h, "DELETE", "/messages", "")
+	rec := do(t, h, "POST", "/echo", `{"text":"after clearing"}`)
+	if got := rec.Header().Get("X-Message-Count"); got != "1" {
+		t.Errorf("X-Message-Count after clearing = %q, want 1", got)
+	}
+}
+
+func TestEchoDryRun(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	h := s.handler(slog.Default())
+	sub := s.hub.subscribe("test", nil)
+
+	rec := do(t, h, "POST", "/echo?dry_run=true&transform=upper", `{"text":"try me"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	got := decode[echoResponse](t, rec)
+	if !got.DryRun || got.Text != "TRY ME" || got.ID != 0 {
+		t.Errorf("response = %+v, want the transformed text, dry_run set and no ID", got)
+	}
+	if rec.Header().Get("X-Message-Count") != "" {
+		t.Error("dry run reported a message count")
+	}
+
+	if n, _ := s.store.Count(t.Context()); n != 0 {
+		t.Errorf("store holds %d messages after a dry run, want 0", n)
+	}
+	if rec := do(t, h, "GET", "/messages/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /messages/1 = %d after a dry run, want 404", rec.Code)
+	}
+	select {
+	case msg := <-sub.send:
+		t.Errorf("dry run broadcast %+v", msg)
+	default:
+	}
+
+	if rec := do(t, h, "POST", "/echo?dry_run=yes", `{"text":"try me"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("dry_run=yes = %d, want 400", rec.Code)
+	}
+}
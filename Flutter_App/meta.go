@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// messageMeta is the metadata POST /echo?meta=true adds to its response.
+type messageMeta struct {
+	Characters int    `json:"characters"`
+	Words      int    `json:"words"`
+	Language   string `json:"language"`
+	SHA256     string `json:"sha256"`
+}
+
+// computeMeta returns the metadata for text.
+func computeMeta(text string) *messageMeta {
+	return &messageMeta{
+		Characters: utf8.RuneCountInString(text),
+		Words:      countWords(text),
+		Language:   detectLanguage(text),
+		SHA256:     sha256Hex(text),
+	}
+}
+
+// parseMeta reads the meta query parameter, which must be absent, "true" or
+// "false".
+func parseMeta(v string) (bool, error) {
+	switch v {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, errors.New("meta must be true or false")
+}
+
+// countWords counts runs of non-space characters.
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// sha256Hex returns the hex-encoded SHA-256 digest of text.
+func sha256Hex(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// scriptLanguages maps scripts used by a single common language to its
+// ISO 639-1 code.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// stopwords lists frequent short words of the Latin-script languages
+// detectLanguage can tell apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "it", "you", "that", "this", "with", "for"},
+	"es": {"el", "la", "los", "las", "y", "es", "de", "que", "en", "un", "una", "por", "con"},
+	"fr": {"le", "la", "les", "et", "est", "de", "des", "que", "un", "une", "pour", "avec", "je"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "ich", "zu", "mit", "für", "sie"},
+}
+
+// detectLanguage guesses the ISO 639-1 code of text's language, or "und"
+// when it cannot tell. It is a heuristic: non-Latin text is classified by
+// script (Japanese wins over Chinese if any kana appears), and Latin text
+// by which language's stopwords it contains most.
+func detectLanguage(text string) string {
+	letters := 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "und"
+	}
+	if scripts["ja"] > 0 && scripts["ja"]+scripts["zh"] > letters/2 {
+		return "ja"
+	}
+	best, bestN := "", 0
+	for _, s := range scriptLanguages {
+		if n := scripts[s.code]; n > bestN {
+			best, bestN = s.code, n
+		}
+	}
+	if bestN > letters/2 {
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	best, bestN = "und", 0
+	for _, code := range []string{"en", "es", "fr", "de"} {
+		n := 0
+		for _, w := range words {
+			for _, sw := range stopwords[code] {
+				if w == sw {
+					n++
+					break
+				}
+			}
+		}
+		if n > bestN {
+			best, bestN = code, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCountWords(t *testing.T) {
+	tests := map[string]int{
+		"":                     0,
+		"   ":                  0,
+		"one":                  1,
+		"  one  two\tthree\n":  3,
+		"héllo wörld":          2,
+		"don't stop-believing": 2,
+	}
+	for text, want := range tests {
+		if got := countWords(text); got != want {
+			t.Errorf("countWords(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestSHA256Hex(t *testing.T) {
+	tests := map[string]string{
+		"":    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"abc": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}
+	for text, want := range tests {
+		if got := sha256Hex(text); got != want {
+			t.Errorf("sha256Hex(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"The cat is on the mat", "en"},
+		{"El perro y el gato", "es"},
+		{"Je suis avec les amis", "fr"},
+		{"Ich bin nicht müde und du", "de"},
+		{"Привет, как дела?", "ru"},
+		{"こんにちは世界", "ja"},
+		{"你好世界", "zh"},
+		{"안녕하세요", "ko"},
+		{"Γειά σου κόσμε", "el"},
+		{"xyzzy plugh", "und"},
+		{"12345 !!", "und"},
+		{"", "und"},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEchoMeta(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	t.Run("without meta", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo", `{"text":"The cat is on the mat"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		if strings.Contains(rec.Body.String(), `"meta"`) {
+			t.Errorf("body = %s, want no meta unless asked for", rec.Body)
+		}
+	})
+
+	t.Run("with meta", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo?meta=true", `{"text":"The cat is on the mat"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		got := decode[echoResponse](t, rec)
+		want := messageMeta{
+			Characters: 21,
+			Words:      6,
+			Language:   "en",
+			SHA256:     sha256Hex("The cat is on the mat"),
+		}
+		if got.Meta == nil || *got.Meta != want {
+			t.Errorf("meta = %+v, want %+v", got.Meta, want)
+		}
+		if got.Text != "The cat is on the mat" || got.ID == 0 {
+			t.Errorf("message = %+v, want the stored message alongside its meta", got.Message)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if rec := do(t, h, "POST", "/echo?meta=yes", `{"text":"hi"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("meta=yes = %d, want 400", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the Prometheus collectors for the server. They live on their
+// own registry rather than the global default so separate servers, such as
+// those created in tests, do not share counts.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests processed, by route, method and status code.",
+		}, []string{"path", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"path", "method"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observeConcurrency exports the number of requests holding a slot in c.
+func (m *metrics) observeConcurrency(c *concurrencyLimiter) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "http_concurrency_slots_in_use",
+		Help: "Requests currently holding a concurrency limiter slot.",
+	}, func() float64 { return float64(c.inFlight()) }))
+}
+
+// observeHub exports the number of connected streaming clients and how many
+// have been evicted for falling behind.
+func (m *metrics) observeHub(h *hub) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "streaming_clients_connected",
+			Help: "WebSocket and server-sent event clients currently connected.",
+		}, func() float64 { return float64(h.count()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "streaming_clients_evicted_total",
+			Help: "Streaming clients disconnected because their send buffer was full.",
+		}, func() float64 { return float64(h.evictions.Load()) }),
+	)
+}
+
+// observeDraining exports whether the server is draining for shutdown.
+func (m *metrics) observeDraining(draining func() bool) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "http_server_draining",
+		Help: "1 while the server is draining connections for shutdown, otherwise 0.",
+	}, func() float64 {
+		if draining() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// handler serves the registry in the Prometheus exposition format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// instrument records request counts, latency and in-flight requests. The
+// path label is the matched route pattern rather than the raw URL so that
+// label cardinality stays bounded.
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		route := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		pattern := *route
+		if pattern == "" {
+			pattern = r.Pattern
+		}
+		path := routeLabel(pattern)
+		m.requests.WithLabelValues(path, r.Method, strconv.Itoa(rw.status)).Inc()
+		m.duration.WithLabelValues(path, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+type routeKey struct{}
+
+// recordRoute reports the pattern matched by the API mux, or by the root mux
+// for probes served at the root, back to instrument. It is needed because
+// the middleware in between, such as http.StripPrefix, hands the mux a copy
+// of the request, so the outer request never sees the Pattern field being
+// set.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			*route = r.Pattern
+		}
+	})
+}
+
+// routeLabel returns the path part of a ServeMux pattern, e.g. "/messages"
+// for "GET /messages". Requests that only matched the catch-all "/" route
+// are reported as "unmatched".
+func routeLabel(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	switch pattern {
+	case "", "/":
+		return "unmatched"
+	case "/{$}":
+		return "/"
+	}
+	return pattern
+}
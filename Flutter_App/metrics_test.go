@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCountRequests(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for range 3 {
+		do(t, h, "GET", "/version", "")
+	}
+	do(t, h, "GET", "/messages/42", "")
+	do(t, h, "GET", "/no/such/path", "")
+
+	rec := do(t, h, "GET", "/metrics", "")
+	body := rec.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",path="/version",status="200"} 3`,
+		`http_requests_total{method="GET",path="/messages/{id}",status="404"} 1`,
+		`http_requests_total{method="GET",path="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",path="/version"} 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+
+	// A second server starts from zero: the registries are not shared.
+	other := newTestServer(t, testConfig()).handler(slog.Default())
+	if body := do(t, other, "GET", "/metrics", "").Body.String(); strings.Contains(body, `path="/version"`) {
+		t.Error("a new server reports requests made to another one")
+	}
+}
+
+func TestMetricsLabelProbesAtRoot(t *testing.T) {
+	cfg := testConfig()
+	cfg.BasePath = "/api"
+	cfg.ProbesAtRoot = true
+	// Rewriting /ready/ hands the root mux a copy of the request, so its
+	// pattern never reaches the request instrument holds.
+	cfg.TrailingSlash = "rewrite"
+	h := newTestServer(t, cfg).handler(slog.Default())
+	do(t, h, "GET", "/health", "")
+	do(t, h, "GET", "/ready/", "")
+	do(t, h, "GET", "/api/health", "")
+
+	body := do(t, h, "GET", "/metrics", "").Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",path="/health",status="200"} 2`,
+		`http_requests_total{method="GET",path="/ready",status="200"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+	if strings.Contains(body, `path="unmatched"`) {
+		t.Error("a probe at the root was labelled unmatched")
+	}
+}
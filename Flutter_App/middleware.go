@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps an http.Handler with additional behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with mw so that the first middleware listed is the outermost:
+// Chain(h, a, b) is equivalent to a(b(h)), and a sees each request first.
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// maxLoggedBody caps how much of a request body is kept for debug logging.
+const maxLoggedBody = 64 << 10
+
+// responseWriter records the status code and number of bytes written so
+// they can be reported after the handler returns.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Flush lets streaming handlers push buffered data through the wrapper.
+func (rw *responseWriter) Flush() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connection upgrades such as WebSocket pass through the
+// wrapper.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil && rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// bodyRecorder captures the first maxLoggedBody bytes the handler reads from
+// the request body without buffering the whole thing up front.
+type bodyRecorder struct {
+	io.ReadCloser
+	buf bytes.Buffer
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxLoggedBody - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+// logRequests emits one structured log line per request. At debug level the
+// request body, as read by the handler, is included as well.
+func logRequests(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		debug := logger.Enabled(r.Context(), slog.LevelDebug)
+
+		var body *bodyRecorder
+		if debug && r.Body != nil {
+			body = &bodyRecorder{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.Int("status", rw.status),
+			slog.Int("size", rw.size),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if body != nil {
+			attrs = append(attrs, slog.String("body", body.buf.String()))
+		}
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// recoverPanics turns a panicking handler into a 500 JSON response and logs
+// the stack trace, instead of net/http's default of dropping the connection.
+// http.ErrAbortHandler is re-panicked since it is net/http's deliberate way
+// of aborting a response.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			slog.ErrorContext(r.Context(), "panic serving request",
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+			)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	h := recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := do(t, h, "GET", "/", "")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	got := decode[errorResponse](t, rec)
+	if got.Error != "Internal server error" || got.Status != http.StatusInternalServerError {
+		t.Errorf("body = %+v, want the generic 500 error", got)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+	marker := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name+" in")
+				next.ServeHTTP(w, r)
+				trace = append(trace, name+" out")
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}), marker("first"), marker("second"))
+
+	do(t, h, "GET", "/", "")
+	want := "first in, second in, handler, second out, first out"
+	if got := strings.Join(trace, ", "); got != want {
+		t.Errorf("ran %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// The types below cover the subset of OpenAPI 3.0 this API needs. The spec
+// is kept next to the handlers so a route change and its documentation land
+// in the same commit.
+
+type openAPIDoc struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       openAPIInfo                `json:"info"`
+	Servers    []openAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components openAPIComponents          `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+// openAPIPathItem maps a lower-case HTTP method to its operation.
+type openAPIPathItem map[string]openAPIOperation
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content,omitempty"`
+}
+
+type openAPIMedia struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	Type       string                    `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Required   []string                  `json:"required,omitempty"`
+	Enum       []string                  `json:"enum,omitempty"`
+	Minimum    *int                      `json:"minimum,omitempty"`
+	Maximum    *int                      `json:"maximum,omitempty"`
+	MinLength  *int                      `json:"minLength,omitempty"`
+	MaxLength  *int                      `json:"maxLength,omitempty"`
+	MaxItems   *int                      `json:"maxItems,omitempty"`
+	ReadOnly   bool                      `json:"readOnly,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+func schemaRef(name string) *openAPISchema {
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMedia {
+	return map[string]openAPIMedia{"application/json": {Schema: schema}}
+}
+
+func jsonResponse(description, schema string) openAPIResponse {
+	return openAPIResponse{Description: description, Content: jsonContent(schemaRef(schema))}
+}
+
+func errorResponseSpec(description string) openAPIResponse {
+	return jsonResponse(description, "Error")
+}
+
+func intPtr(n int) *int { return &n }
+
+// openAPISpec describes the JSON routes registered in routes. Limits that
+// come from the configuration are filled in from cfg.
+func openAPISpec(cfg Config) openAPIDoc {
+	bearer := []map[string][]string{{"bearerAuth": {}}}
+	idParam := openAPIParameter{
+		Name: "id", In: "path", Required: true,
+		Schema: &openAPISchema{Type: "integer", Format: "int64", Minimum: intPtr(1)},
+	}
+
+	doc := openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Flutter App API", Version: gitCommit},
+		Paths: map[string]openAPIPathItem{
+			"/": {
+				"get": {
+					Summary:     "Return a greeting",
+					OperationID: "hello",
+					Parameters: []openAPIParameter{
+						{
+							Name: "lang", In: "query",
+							Description: "Greeting language; overrides Accept-Language",
+							Schema:      &openAPISchema{Type: "string", Enum: greetingLanguages()},
+						},
+						{Name: "Accept-Language", In: "header", Schema: &openAPISchema{Type: "string"}},
+					},
+					Responses: map[string]openAPIResponse{"200": jsonResponse("Greeting", "Message")},
+				},
+			},
+			"/echo": {
+				"post": {
+					Summary:     "Store a message and echo it back",
+					OperationID: "echo",
+					Parameters: []openAPIParameter{
+						{
+							Name: "transform", In: "query",
+							Description: "Transformation applied to the text before it is stored",
+							Schema:      &openAPISchema{Type: "string", Enum: transformNames()},
+						},
+						{
+							Name: "ttl_seconds", In: "query",
+							Description: "Delete the message after this many seconds instead of the server default",
+							Schema:      &openAPISchema{Type: "integer", Minimum: intPtr(1)},
+						},
+						{
+							Name: "dry_run", In: "query",
+							Description: "Validate and transform the message without storing it",
+							Schema:      &openAPISchema{Type: "boolean"},
+						},
+						{
+							Name: "meta", In: "query",
+							Description: "Add computed metadata about the text to the response",
+							Schema:      &openAPISchema{Type: "boolean"},
+						},
+						{
+							Name: idempotencyKeyHeader, In: "header",
+							Description: "Retries with the same key and body return the original response",
+							Schema:      &openAPISchema{Type: "string", MaxLength: intPtr(maxIdempotencyKeyLen)},
+						},
+					},
+					RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(schemaRef("MessageInput"))},
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("The stored message, or with dry_run the message that would be stored", "Message"),
+						"400": errorResponseSpec("Malformed body or unknown transform"),
+						"401": errorResponseSpec("Missing or invalid bearer token"),
+						"409": errorResponseSpec("Idempotency-Key reused with a different body, or still in progress"),
+						"413": errorResponseSpec("Body exceeds " + strconv.FormatInt(cfg.MaxBodyBytes, 10) + " bytes"),
+						"415": errorResponseSpec("Content-Type is not application/json"),
+						"422": jsonResponse("Message failed validation", "ValidationError"),
+						"429": errorResponseSpec("Rate limit exceeded"),
+						"503": errorResponseSpec("Storage unavailable or timed out"),
+					},
+					Security: bearer,
+				},
+			},
+			"/echo/stream": {
+				"post": {
+					Summary:     "Stream the request body back unchanged",
+					OperationID: "echoStream",
+					RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMedia{
+						"application/octet-stream": {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+					}},
+					Responses: map[string]openAPIResponse{
+						"200": {Description: "The request body, with the request's Content-Type; truncated if it exceeds the body limit"},
+						"401": errorResponseSpec("Missing or invalid bearer token"),
+						"413": errorResponseSpec("Content-Length exceeds " + strconv.FormatInt(cfg.MaxBodyBytes, 10) + " bytes"),
+						"429": errorResponseSpec("Rate limit exceeded"),
+					},
+					Security: bearer,
+				},
+			},
+			"/echo/batch": {
+				"post": {
+					Summary:     "Store several messages, reporting a result per item",
+					OperationID: "echoBatch",
+					Parameters: []openAPIParameter{{
+						Name: "transform", In: "query",
+						Description: "Transformation applied to each text before it is stored",
+						Schema:      &openAPISchema{Type: "string", Enum: transformNames()},
+					}},
+					RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(&openAPISchema{
+						Type:     "array",
+						Items:    schemaRef("MessageInput"),
+						MaxItems: intPtr(cfg.MaxBatchSize),
+					})},
+					Responses: map[string]openAPIResponse{
+						"200": {Description: "One result per input item, in order", Content: jsonContent(&openAPISchema{
+							Type:  "array",
+							Items: schemaRef("BatchResult"),
+						})},
+						"400": errorResponseSpec("Malformed body, empty or oversized batch, or unknown transform"),
+						"401": errorResponseSpec("Missing or invalid bearer token"),
+						"413": errorResponseSpec("Body exceeds " + strconv.FormatInt(cfg.MaxBodyBytes, 10) + " bytes"),
+						"415": errorResponseSpec("Content-Type is not application/json"),
+						"429": errorResponseSpec("Rate limit exceeded"),
+					},
+					Security: bearer,
+				},
+			},
+			"/upload": {
+				"post": {
+					Summary:     "Upload a file",
+					OperationID: "upload",
+					RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMedia{
+						"multipart/form-data": {Schema: &openAPISchema{
+							Type:       "object",
+							Required:   []string{"file"},
+							Properties: map[string]*openAPISchema{"file": {Type: "string", Format: "binary"}},
+						}},
+					}},
+					Responses: map[string]openAPIResponse{
+						"201": jsonResponse("The stored file", "Upload"),
+						"400": errorResponseSpec("Malformed form or disallowed file type"),
+						"401": errorResponseSpec("Missing or invalid bearer token"),
+						"413": errorResponseSpec("File exceeds " + strconv.FormatInt(cfg.UploadMaxBytes, 10) + " bytes"),
+						"429": errorResponseSpec("Rate limit exceeded"),
+					},
+					Security: bearer,
+				},
+			},
+			"/messages": {
+				"get": {
+					Summary:     "List stored messages, oldest first",
+					OperationID: "listMessages",
+					Parameters: []openAPIParameter{
+						{Name: "limit", In: "query", Schema: &openAPISchema{Type: "integer", Minimum: intPtr(0), Maximum: intPtr(maxPageLimit)}},
+						{Name: "offset", In: "query", Schema: &openAPISchema{Type: "integer", Minimum: intPtr(0)}},
+						{Name: "If-None-Match", In: "header", Schema: &openAPISchema{Type: "string"}},
+					},
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("A page of messages", "MessagePage"),
+						"304": {Description: "The page is unchanged since the ETag given in If-None-Match"},
+						"400": errorResponseSpec("Invalid limit or offset"),
+						"503": errorResponseSpec("Storage unavailable or timed out"),
+					},
+				},
+				"delete": {
+					Summary:     "Delete every stored message",
+					OperationID: "clearMessages",
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("Number of messages deleted", "ClearResult"),
+						"401": errorResponseSpec("Missing or invalid bearer token"),
+						"503": errorResponseSpec("Storage unavailable or timed out"),
+					},
+					Security: bearer,
+				},
+			},
+			"/messages/search": {
+				"get": {
+					Summary:     "Search stored messages by text, ignoring case",
+					OperationID: "searchMessages",
+					Parameters: []openAPIParameter{
+						{Name: "q", In: "query", Required: true, Schema: &openAPISchema{Type: "string", MinLength: intPtr(1)}},
+						{Name: "limit", In: "query", Schema: &openAPISchema{Type: "integer", Minimum: intPtr(0), Maximum: intPtr(maxPageLimit)}},
+						{Name: "offset", In: "query", Schema: &openAPISchema{Type: "integer", Minimum: intPtr(0)}},
+					},
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("A page of matching messages; total counts every match", "MessagePage"),
+						"400": errorResponseSpec("Missing q, or invalid limit or offset"),
+						"503": errorResponseSpec("Storage unavailable or timed out"),
+					},
+				},
+			},
+			"/messages/{id}": {
+				"get": {
+					Summary:     "Fetch a single message",
+					OperationID: "getMessage",
+					Parameters:  []openAPIParameter{idParam},
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("The message", "Message"),
+						"400": errorResponseSpec("Message ID is not a positive integer"),
+						"404": errorResponseSpec("No message with that ID"),
+						"503": errorResponseSpec("Storage unavailable or timed out"),
+					},
+				},
+			},
+			"/health": {
+				"get": {
+					Summary:     "Report liveness and dependency health",
+					OperationID: "health",
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("Healthy, or degraded but serving", "Health"),
+						"503": jsonResponse("One or more checks failing", "Health"),
+					},
+				},
+			},
+			"/ready": {
+				"get": {
+					Summary:     "Report whether the server is accepting new traffic",
+					OperationID: "ready",
+					Responses: map[string]openAPIResponse{
+						"200": jsonResponse("Accepting traffic", "Ready"),
+						"503": jsonResponse("Shutting down; in-flight requests are draining", "Ready"),
+					},
+				},
+			},
+			"/stats": {
+				"get": {
+					Summary:     "Report message throughput",
+					OperationID: "stats",
+					Responses:   map[string]openAPIResponse{"200": jsonResponse("Message counts and rate", "Stats")},
+				},
+			},
+			"/version": {
+				"get": {
+					Summary:     "Report build metadata",
+					OperationID: "version",
+					Responses:   map[string]openAPIResponse{"200": jsonResponse("Build metadata", "Version")},
+				},
+			},
+		},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{
+				"MessageInput": {
+					Type:     "object",
+					Required: []string{"text"},
+					Properties: map[string]*openAPISchema{
+						"text": {Type: "string", MaxLength: intPtr(cfg.MaxMessageLength)},
+					},
+				},
+				"Message": {
+					Type:     "object",
+					Required: []string{"text"},
+					Properties: map[string]*openAPISchema{
+						"id":          {Type: "integer", Format: "int64", ReadOnly: true},
+						"text":        {Type: "string"},
+						"received_at": {Type: "string", Format: "date-time", ReadOnly: true},
+						"expires_at":  {Type: "string", Format: "date-time", ReadOnly: true},
+						"dry_run":     {Type: "boolean", ReadOnly: true},
+						"meta":        schemaRef("MessageMeta"),
+					},
+				},
+				"MessageMeta": {
+					Type:     "object",
+					Required: []string{"characters", "words", "language", "sha256"},
+					Properties: map[string]*openAPISchema{
+						"characters": {Type: "integer"},
+						"words":      {Type: "integer"},
+						"language":   {Type: "string"},
+						"sha256":     {Type: "string"},
+					},
+				},
+				"MessagePage": {
+					Type:     "object",
+					Required: []string{"messages", "total", "limit", "offset"},
+					Properties: map[string]*openAPISchema{
+						"messages": {Type: "array", Items: schemaRef("Message")},
+						"total":    {Type: "integer", Format: "int64"},
+						"limit":    {Type: "integer"},
+						"offset":   {Type: "integer"},
+					},
+				},
+				"BatchResult": {
+					Type:     "object",
+					Required: []string{"status"},
+					Properties: map[string]*openAPISchema{
+						"status":  {Type: "string", Enum: []string{"ok", "error"}},
+						"message": schemaRef("Message"),
+						"error":   {Type: "string"},
+						"errors":  {Type: "array", Items: schemaRef("FieldError")},
+					},
+				},
+				"ClearResult": {
+					Type:       "object",
+					Required:   []string{"deleted"},
+					Properties: map[string]*openAPISchema{"deleted": {Type: "integer"}},
+				},
+				"Upload": {
+					Type:     "object",
+					Required: []string{"filename", "size", "content_type"},
+					Properties: map[string]*openAPISchema{
+						"filename":     {Type: "string"},
+						"size":         {Type: "integer", Format: "int64"},
+						"content_type": {Type: "string", Enum: cfg.UploadAllowedTypes},
+					},
+				},
+				"Health": {
+					Type:     "object",
+					Required: []string{"status", "uptime_seconds"},
+					Properties: map[string]*openAPISchema{
+						"status":         {Type: "string", Enum: []string{"ok", "degraded", "unavailable"}},
+						"uptime_seconds": {Type: "integer", Format: "int64"},
+						"failing_checks": {Type: "array", Items: &openAPISchema{
+							Type:     "object",
+							Required: []string{"name", "error"},
+							Properties: map[string]*openAPISchema{
+								"name":  {Type: "string"},
+								"error": {Type: "string"},
+							},
+						}},
+					},
+				},
+				"Ready": {
+					Type:       "object",
+					Required:   []string{"status"},
+					Properties: map[string]*openAPISchema{"status": {Type: "string", Enum: []string{"ready", "draining"}}},
+				},
+				"LogLevel": {
+					Type:       "object",
+					Required:   []string{"level"},
+					Properties: map[string]*openAPISchema{"level": {Type: "string", Enum: []string{"info", "debug"}}},
+				},
+				"ShutdownStatus": {
+					Type:       "object",
+					Required:   []string{"status"},
+					Properties: map[string]*openAPISchema{"status": {Type: "string", Enum: []string{"shutting down"}}},
+				},
+				"Stats": {
+					Type:     "object",
+					Required: []string{"messages_total", "rate_per_min", "window_start"},
+					Properties: map[string]*openAPISchema{
+						"messages_total": {Type: "integer", Format: "int64"},
+						"rate_per_min":   {Type: "number"},
+						"window_start":   {Type: "string", Format: "date-time"},
+					},
+				},
+				"Version": {
+					Type: "object",
+					Properties: map[string]*openAPISchema{
+						"git_commit": {Type: "string"},
+						"build_time": {Type: "string"},
+						"go_version": {Type: "string"},
+					},
+				},
+				"Error": {
+					Type:     "object",
+					Required: []string{"error", "status"},
+					Properties: map[string]*openAPISchema{
+						"error":  {Type: "string"},
+						"status": {Type: "integer"},
+					},
+				},
+				"FieldError": {
+					Type:     "object",
+					Required: []string{"field", "pointer", "message"},
+					Properties: map[string]*openAPISchema{
+						"index":   {Type: "integer"},
+						"field":   {Type: "string"},
+						"pointer": {Type: "string"},
+						"message": {Type: "string"},
+					},
+				},
+				"ValidationError": {
+					Type:     "object",
+					Required: []string{"error", "status", "errors"},
+					Properties: map[string]*openAPISchema{
+						"error":  {Type: "string"},
+						"status": {Type: "integer"},
+						"errors": {Type: "array", Items: schemaRef("FieldError")},
+					},
+				},
+			},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	// The admin routes are only registered when configured; see routeTable.
+	if cfg.authConfigured() {
+		doc.Paths["/admin/loglevel"] = openAPIPathItem{
+			"get": {
+				Summary:     "Report the current log level",
+				OperationID: "getLogLevel",
+				Responses: map[string]openAPIResponse{
+					"200": jsonResponse("Current log level", "LogLevel"),
+					"401": errorResponseSpec("Missing or invalid bearer token"),
+				},
+				Security: bearer,
+			},
+			"post": {
+				Summary:     "Change the log level without a restart",
+				OperationID: "setLogLevel",
+				RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(schemaRef("LogLevel"))},
+				Responses: map[string]openAPIResponse{
+					"200": jsonResponse("The new log level", "LogLevel"),
+					"400": errorResponseSpec("Malformed body or unknown level"),
+					"401": errorResponseSpec("Missing or invalid bearer token"),
+					"413": errorResponseSpec("Body exceeds " + strconv.FormatInt(cfg.MaxBodyBytes, 10) + " bytes"),
+					"415": errorResponseSpec("Content-Type is not application/json"),
+				},
+				Security: bearer,
+			},
+		}
+	}
+	if cfg.AdminShutdown {
+		doc.Paths["/admin/shutdown"] = openAPIPathItem{
+			"post": {
+				Summary:     "Start a graceful shutdown, like SIGTERM",
+				OperationID: "adminShutdown",
+				Responses: map[string]openAPIResponse{
+					"202": jsonResponse("Shutdown started; in-flight requests are draining", "ShutdownStatus"),
+					"401": errorResponseSpec("Missing or invalid bearer token"),
+					"409": errorResponseSpec("Server is already shutting down"),
+				},
+				Security: bearer,
+			},
+		}
+	}
+	if cfg.BasePath != "" {
+		doc.Servers = []openAPIServer{{URL: cfg.BasePath}}
+	}
+	return doc
+}
+
+func (s *server) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec(s.cfg))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// undocumentedRoutes are served but left out of the OpenAPI document:
+// streaming and tooling endpoints that OpenAPI 3.0 cannot usefully describe.
+var undocumentedRoutes = []string{"/ws", "/events", "/metrics", "/openapi.json"}
+
+func TestOpenAPISpec(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		tokens []string
+	}{
+		{"without auth", nil},
+		{"with auth", []string{"s3cret"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.AuthTokens = tt.tokens
+			cfg.AdminShutdown = tt.tokens != nil
+			s := newTestServer(t, cfg)
+
+			rec := do(t, s.handler(slog.Default()), "GET", "/openapi.json", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			doc := decode[struct {
+				OpenAPI string                                `json:"openapi"`
+				Paths   map[string]map[string]json.RawMessage `json:"paths"`
+			}](t, rec)
+			if doc.OpenAPI != "3.0.3" {
+				t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+			}
+
+			// Every route is documented under its method, and nothing else is.
+			documented := 0
+			for _, rt := range s.routeTable() {
+				path := strings.TrimSuffix(rt.path, "{$}")
+				if slices.Contains(undocumentedRoutes, path) {
+					continue
+				}
+				documented++
+				if _, ok := doc.Paths[path][strings.ToLower(rt.method)]; !ok {
+					t.Errorf("%s %s is not documented", rt.method, path)
+				}
+			}
+			operations := 0
+			for _, item := range doc.Paths {
+				operations += len(item)
+			}
+			if operations != documented {
+				t.Errorf("spec documents %d operations, want the %d routes", operations, documented)
+			}
+		})
+	}
+}
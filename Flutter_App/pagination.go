@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePagination reads the limit and offset query parameters. Missing
+// values fall back to the defaults and limit is clamped to maxPageLimit.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	q := r.URL.Query()
+
+	limit, err = parseNonNegative(q.Get("limit"), "limit", defaultPageLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	offset, err = parseNonNegative(q.Get("offset"), "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	return min(limit, maxPageLimit), offset, nil
+}
+
+func parseNonNegative(v, name string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
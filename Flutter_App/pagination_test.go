@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestListMessagesPagination(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for _, text := range []string{"a", "b", "c", "d", "e"} {
+		postMessage(t, h, text)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		ids           []int64
+		limit, offset int
+	}{
+		{"default page", "", []int64{1, 2, 3, 4, 5}, defaultPageLimit, 0},
+		{"custom page", "?limit=2&offset=1", []int64{2, 3}, 2, 1},
+		{"limit clamped", "?limit=1000", []int64{1, 2, 3, 4, 5}, maxPageLimit, 0},
+		{"offset out of range", "?offset=10", nil, defaultPageLimit, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, "GET", "/messages"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), `"messages":[`) {
+				t.Errorf("messages is not an array: %s", rec.Body)
+			}
+			page := decode[messagePage](t, rec)
+			var ids []int64
+			for _, msg := range page.Messages {
+				ids = append(ids, msg.ID)
+			}
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("ids = %v, want %v", ids, tt.ids)
+			}
+			if page.Total != 5 || page.Limit != tt.limit || page.Offset != tt.offset {
+				t.Errorf("total, limit, offset = %d, %d, %d, want 5, %d, %d",
+					page.Total, page.Limit, page.Offset, tt.limit, tt.offset)
+			}
+		})
+	}
+
+	for _, query := range []string{"?limit=-1", "?limit=ten", "?offset=-5", "?offset=1.5"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			rec := do(t, h, "GET", "/messages"+query, "")
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
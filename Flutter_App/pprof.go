@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofPath is the subtree the profiles are served under. Its index needs
+// the trailing slash, so normalizeSlashes leaves it alone.
+const pprofPath = "/debug/pprof/"
+
+// pprofHandler serves the runtime profiles under /debug/pprof/. They get a
+// mux of their own, registered on the API mux as one subtree, so the route
+// table only lists the API. Importing net/http/pprof also registers these
+// handlers on http.DefaultServeMux, which this server never serves.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+pprofPath, pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPprof(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.AuthTokens = []string{"s3cret"}
+		h := newTestServer(t, cfg).handler(slog.Default())
+		if rec := do(t, h, "GET", pprofPath, "", "Authorization", "Bearer s3cret"); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404 when pprof is off", pprofPath, rec.Code)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.AuthTokens = []string{"s3cret"}
+		cfg.EnablePprof = true
+		if err := cfg.validate(); err != nil {
+			t.Fatal(err)
+		}
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		if rec := do(t, h, "GET", pprofPath, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s without a token = %d, want 401", pprofPath, rec.Code)
+		}
+		rec := do(t, h, "GET", pprofPath, "", "Authorization", "Bearer s3cret")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+			t.Errorf("GET %s = %d, want the profile index", pprofPath, rec.Code)
+		}
+		rec = do(t, h, "GET", pprofPath+"goroutine?debug=1", "", "Authorization", "Bearer s3cret")
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET goroutine profile = %d, want 200", rec.Code)
+		}
+	})
+
+	t.Run("requires auth", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.EnablePprof = true
+		if err := cfg.validate(); err == nil {
+			t.Error("config with pprof and no auth tokens validated")
+		}
+	})
+}
+
+func TestPprofOutlivesWriteTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.AuthTokens = []string{"s3cret"}
+	cfg.EnablePprof = true
+	cfg.WriteTimeout = 500 * time.Millisecond
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(cfg, newTestServer(t, cfg).handler(slog.Default()))
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+pprofPath+"trace?seconds=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading a trace longer than WriteTimeout: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("trace for twice WriteTimeout = %d with %d bytes, want 200 and the trace: %s", res.StatusCode, len(body), body)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdleTTL is how long a client's limiter is kept after its last
+	// request.
+	limiterIdleTTL = 3 * time.Minute
+	// limiterSweepInterval is how often idle limiters are evicted.
+	limiterSweepInterval = time.Minute
+)
+
+// rateLimiter applies a token bucket per client IP.
+type rateLimiter struct {
+	mu         sync.Mutex
+	visitors   map[string]*visitor
+	rate       rate.Limit
+	burst      int
+	trustProxy bool
+}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter allows each client perSecond requests per second with bursts
+// of up to burst requests.
+func newRateLimiter(perSecond float64, burst int, trustProxy bool) *rateLimiter {
+	return &rateLimiter{
+		visitors:   make(map[string]*visitor),
+		rate:       rate.Limit(perSecond),
+		burst:      burst,
+		trustProxy: trustProxy,
+	}
+}
+
+func (rl *rateLimiter) get(ip string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	v, ok := rl.visitors[ip]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.visitors[ip] = v
+	}
+	v.lastSeen = time.Now()
+	return v.limiter
+}
+
+// sweep periodically evicts limiters that have been idle longer than
+// limiterIdleTTL, until ctx is cancelled.
+func (rl *rateLimiter) sweep(ctx context.Context) {
+	ticker := time.NewTicker(limiterSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rl.mu.Lock()
+			for ip, v := range rl.visitors {
+				if now.Sub(v.lastSeen) > limiterIdleTTL {
+					delete(rl.visitors, ip)
+				}
+			}
+			rl.mu.Unlock()
+		}
+	}
+}
+
+// limit wraps a route so each client IP is held to the configured rate.
+// Clients over the limit get a 429 with a Retry-After header.
+func (rl *rateLimiter) limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := rl.get(clientIP(r, rl.trustProxy)).Reserve()
+		if delay := res.Delay(); delay > 0 {
+			res.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP returns the address of the client that made r. X-Forwarded-For
+// is only honoured when trustProxy is set, since any client can send it,
+// and then only its last entry, which the trusted proxy added.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := lastForwarded(r.Header, "X-Forwarded-For"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimit = 0.001
+	cfg.RateBurst = 3
+	h := newTestServer(t, cfg).handler(slog.Default())
+
+	for i := range cfg.RateBurst {
+		if rec := do(t, h, "POST", "/echo", `{"text":"hi"}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200 within the burst", i, rec.Code)
+		}
+	}
+	rec := do(t, h, "POST", "/echo", `{"text":"hi"}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After not set")
+	}
+
+	// Other clients have their own bucket.
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader(`{"text":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "198.51.100.7:4000"
+	other := httptest.NewRecorder()
+	h.ServeHTTP(other, req)
+	if other.Code != http.StatusOK {
+		t.Errorf("another client = %d, want 200", other.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		xff        []string
+		trustProxy bool
+		want       string
+	}{
+		{"remote address", nil, false, "192.0.2.1"},
+		{"untrusted forwarded header", []string{"203.0.113.9"}, false, "192.0.2.1"},
+		{"trusted proxy", []string{"203.0.113.9"}, true, "203.0.113.9"},
+		{"spoofed entries before the proxy's", []string{"10.9.9.9, 203.0.113.9"}, true, "203.0.113.9"},
+		{"entry from a second header line", []string{"10.9.9.9", "203.0.113.9"}, true, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := clientIP(r, tt.trustProxy); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&logs, nil)})
+	h := newTestServer(t, testConfig()).handler(logger)
+
+	tests := []struct {
+		name     string
+		supplied string
+		reused   bool
+	}{
+		{"generated", "", false},
+		{"client supplied", "abc-123", true},
+		{"invalid replaced", "has spaces\tand tabs", false},
+		{"too long replaced", strings.Repeat("x", maxRequestIDLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			var header []string
+			if tt.supplied != "" {
+				header = []string{requestIDHeader, tt.supplied}
+			}
+			rec := do(t, h, "GET", "/version", "", header...)
+
+			id := rec.Header().Get(requestIDHeader)
+			if tt.reused && id != tt.supplied {
+				t.Errorf("%s = %q, want the supplied %q", requestIDHeader, id, tt.supplied)
+			}
+			if !tt.reused && (len(id) != 32 || id == tt.supplied) {
+				t.Errorf("%s = %q, want a generated 32-character ID", requestIDHeader, id)
+			}
+			if !strings.Contains(logs.String(), `"request_id":"`+id+`"`) {
+				t.Errorf("request log does not carry the ID %q: %s", id, logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errorResponse is the body sent for every non-2xx response so clients can
+// always decode JSON.
+type errorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// writeJSON encodes v as the response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes {"error":msg,"status":status} with the given status
+// code. It replaces http.Error, which always responds in plain text.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg, Status: status})
+}
+
+// plainTexter is implemented by response values that have a plain-text
+// form.
+type plainTexter interface {
+	plainText() string
+}
+
+// respond writes data with a 200 status, as plain text when the client
+// prefers text/plain over JSON and data has a plain-text form, and as JSON
+// otherwise.
+func respond(w http.ResponseWriter, r *http.Request, data any) {
+	w.Header().Add("Vary", "Accept")
+	if pt, ok := data.(plainTexter); ok && prefersPlainText(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, pt.plainText()+"\n")
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// prefersPlainText reports whether the Accept header ranks text/plain above
+// application/json. A missing header, */* or a tie all mean JSON.
+func prefersPlainText(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	ranges := parseAccept(accept)
+	return acceptQuality(ranges, "text", "plain") > acceptQuality(ranges, "application", "json")
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+		if !ok {
+			continue
+		}
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// acceptQuality returns the q-value the most specific matching range gives
+// typ/subtype, or 0 if no range matches.
+func acceptQuality(ranges []mediaRange, typ, subtype string) float64 {
+	best, q := -1, 0.0
+	for _, mr := range ranges {
+		specificity := -1
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			specificity = 2
+		case mr.typ == typ && mr.subtype == "*":
+			specificity = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			specificity = 0
+		}
+		if specificity > best {
+			best, q = specificity, mr.q
+		}
+	}
+	return q
+}
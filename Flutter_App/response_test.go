@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestJSONErrors(t *testing.T) {
+	h := newTestServer(t, defaultConfig()).handler(slog.Default())
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		status       int
+		error        string
+	}{
+		{"unknown path", "GET", "/nope", "", http.StatusNotFound, "Not found"},
+		{"malformed body", "POST", "/echo", "{", http.StatusBadRequest, ""},
+		{"wrong method", "PUT", "/echo", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			got := decode[errorResponse](t, rec)
+			if got.Status != tt.status {
+				t.Errorf("body status = %d, want %d", got.Status, tt.status)
+			}
+			if got.Error == "" || tt.error != "" && got.Error != tt.error {
+				t.Errorf("body error = %q, want %q", got.Error, tt.error)
+			}
+		})
+	}
+}
+
+func TestEchoContentNegotiation(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	tests := []struct {
+		accept string
+		plain  bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{"text/plain", true},
+		{"text/*", true},
+		{"text/plain, application/json;q=0.5", true},
+		{"application/json, text/plain;q=0.9", false},
+		{"text/plain, application/json", false},
+		{"image/png", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			var header []string
+			if tt.accept != "" {
+				header = []string{"Accept", tt.accept}
+			}
+			rec := do(t, h, "POST", "/echo", `{"text":"hi"}`, header...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			ct := rec.Header().Get("Content-Type")
+			if tt.plain {
+				if ct != "text/plain; charset=utf-8" || rec.Body.String() != "hi\n" {
+					t.Errorf("got %q %q, want the plain text message", ct, rec.Body)
+				}
+				return
+			}
+			if ct != "application/json" || decode[Message](t, rec).Text != "hi" {
+				t.Errorf("got %q %q, want the JSON message", ct, rec.Body)
+			}
+		})
+	}
+}
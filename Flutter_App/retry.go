@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the backoff cap before the second attempt; it
+	// doubles with each further attempt up to retryMaxDelay.
+	retryBaseDelay = 10 * time.Millisecond
+	retryMaxDelay  = 500 * time.Millisecond
+
+	// SQLite primary result codes for a database or table held by another
+	// connection. Extended codes carry these in their low byte.
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// isTransient reports whether err is a SQLite busy or locked error, which
+// can succeed if simply tried again. Anything else, constraint violations
+// included, is permanent.
+func isTransient(err error) bool {
+	var coded interface{ Code() int }
+	if !errors.As(err, &coded) {
+		return false
+	}
+	switch coded.Code() & 0xff {
+	case sqliteBusy, sqliteLocked:
+		return true
+	}
+	return false
+}
+
+// withRetry runs op up to attempts times while it fails with a transient
+// error, sleeping a random, exponentially growing delay between tries. The
+// last error is returned if every attempt fails or ctx ends first.
+func withRetry(ctx context.Context, attempts int, op func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= attempts || !isTransient(err) {
+			return err
+		}
+
+		timer := time.NewTimer(rand.N(delay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		delay = min(2*delay, retryMaxDelay)
+	}
+}
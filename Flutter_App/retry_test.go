@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// sqliteError mimics the driver's error type, which reports its result code
+// through a Code method.
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e sqliteError) Code() int     { return int(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"busy", sqliteError(sqliteBusy), true},
+		{"locked", sqliteError(sqliteLocked), true},
+		{"extended busy", sqliteError(sqliteBusy | 2<<8), true},
+		{"wrapped", fmt.Errorf("save: %w", sqliteError(sqliteBusy)), true},
+		{"constraint", sqliteError(19), false},
+		{"uncoded", errors.New("disk I/O error"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: isTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	// failing returns an op that fails with err the first n times.
+	failing := func(n int, err error, calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= n {
+				return err
+			}
+			return nil
+		}
+	}
+	busy := sqliteError(sqliteBusy)
+
+	t.Run("recovers after transient errors", func(t *testing.T) {
+		var calls int
+		if err := withRetry(t.Context(), 5, failing(2, busy, &calls)); err != nil {
+			t.Fatalf("withRetry = %v, want success on the third attempt", err)
+		}
+		if calls != 3 {
+			t.Errorf("op ran %d times, want 3", calls)
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		var calls int
+		if err := withRetry(t.Context(), 2, failing(2, busy, &calls)); !errors.Is(err, busy) {
+			t.Fatalf("withRetry = %v, want the last busy error", err)
+		}
+		if calls != 2 {
+			t.Errorf("op ran %d times, want 2", calls)
+		}
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		var calls int
+		constraint := sqliteError(19)
+		if err := withRetry(t.Context(), 5, failing(2, constraint, &calls)); !errors.Is(err, constraint) {
+			t.Fatalf("withRetry = %v, want the constraint error", err)
+		}
+		if calls != 1 {
+			t.Errorf("op ran %d times, want 1", calls)
+		}
+	})
+
+	t.Run("stops when the context ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		var calls int
+		if err := withRetry(ctx, 5, failing(5, busy, &calls)); !errors.Is(err, busy) {
+			t.Fatalf("withRetry = %v, want the busy error", err)
+		}
+		// A backoff timer that fires at once may still win one more try.
+		if calls > 2 {
+			t.Errorf("op ran %d times after cancellation, want it to stop retrying", calls)
+		}
+	})
+}
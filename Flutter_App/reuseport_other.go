@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// reusePortSupported reports whether this platform has SO_REUSEPORT.
+const reusePortSupported = false
+
+// reusePortControl always fails; Config.validate rejects ReusePort on this
+// platform before a listener is created.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+}
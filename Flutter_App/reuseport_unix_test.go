@@ -0,0 +1,56 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReusePortSharesAddress(t *testing.T) {
+	cfg := testConfig()
+	cfg.Addr = "127.0.0.1:0"
+	cfg.ReusePort = true
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	// The second listener takes the port the kernel picked for the first,
+	// as a new process would during a restart.
+	cfg.Addr = first.Addr().String()
+	second, err := listen(cfg)
+	if err != nil {
+		t.Fatalf("second listener on %s: %v", cfg.Addr, err)
+	}
+	defer second.Close()
+
+	cfg.ReusePort = false
+	if ln, err := listen(cfg); err == nil {
+		ln.Close()
+		t.Errorf("listener without SO_REUSEPORT bound %s too", cfg.Addr)
+	}
+
+	// Closing the old listener leaves the new one accepting.
+	first.Close()
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := second.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	conn, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := <-accepted; err != nil {
+		t.Errorf("second listener Accept: %v", err)
+	}
+}
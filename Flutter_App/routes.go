@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// route is one entry in a route table: a handler for a method on a path
+// pattern.
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// standardMethods are the methods a path's 405 fallback is registered for.
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// handle registers every route on mux. Each path also gets a fallback
+// answering 405 with an Allow header, so a known path requested with the
+// wrong method is not mistaken for an unknown one by the catch-all 404.
+// The fallback is registered per method rather than method-less, which
+// ServeMux would reject as conflicting when a literal path such as
+// /messages/search sits next to a wildcard such as GET /messages/{id}.
+func handle(mux *http.ServeMux, routes []route) {
+	allowed := make(map[string][]string)
+	var paths []string
+	for _, rt := range routes {
+		mux.Handle(rt.method+" "+rt.path, rt.handler)
+		if _, ok := allowed[rt.path]; !ok {
+			paths = append(paths, rt.path)
+		}
+		allowed[rt.path] = append(allowed[rt.path], rt.method)
+	}
+	for _, path := range paths {
+		methods := allowed[path]
+		// ServeMux serves HEAD with the GET handler.
+		if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+			methods = append(methods, http.MethodHead)
+		}
+		fallback := methodNotAllowed(methods)
+		for _, m := range standardMethods {
+			if !slices.Contains(methods, m) {
+				mux.Handle(m+" "+path, fallback)
+			}
+		}
+	}
+}
+
+func methodNotAllowed(methods []string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" not allowed, use "+allow)
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestRouteMethods(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	tests := []struct {
+		method, target string
+		status         int
+		allow          string
+	}{
+		{"GET", "/messages", http.StatusOK, ""},
+		{"HEAD", "/messages", http.StatusOK, ""},
+		{"PUT", "/messages", http.StatusMethodNotAllowed, "GET, DELETE, HEAD"},
+		{"GET", "/echo", http.StatusMethodNotAllowed, "POST"},
+		{"DELETE", "/messages/7", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"POST", "/messages/search", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"GET", "/no/such/route", http.StatusNotFound, ""},
+		{"POST", "/no/such/route", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.target, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+			if tt.status == http.StatusMethodNotAllowed {
+				want := "Method " + tt.method + " not allowed, use " + tt.allow
+				if got := decode[errorResponse](t, rec); got.Error != want {
+					t.Errorf("error = %q, want %q", got.Error, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// messageSchema describes the message payload accepted by the echo
+// endpoints.
+var messageSchema = mustCompileSchema("schemas/message.json")
+
+// mustCompileSchema compiles the embedded schema at path. It panics on
+// error, since the schemas ship with the binary and a broken one is a bug.
+func mustCompileSchema(path string) *jsonschema.Schema {
+	data, err := schemaFiles.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(path, doc); err != nil {
+		panic(err)
+	}
+	return c.MustCompile(path)
+}
+
+// validateJSON checks raw against sch, returning every violation as a
+// *validationError whose pointers locate the offending values in raw.
+func validateJSON(sch *jsonschema.Schema, raw []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return badRequest("body is not valid JSON")
+	}
+	err = sch.Validate(doc)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	var v validationError
+	for _, unit := range ve.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		// Point missing and unexpected properties at the property itself
+		// rather than at the object that does or does not contain it.
+		switch k := unit.Error.Kind.(type) {
+		case *kind.Required:
+			for _, name := range k.Missing {
+				v.Errors = append(v.Errors, propertyError(unit.InstanceLocation, name, "is required"))
+			}
+		case *kind.AdditionalProperties:
+			for _, name := range k.Properties {
+				v.Errors = append(v.Errors, propertyError(unit.InstanceLocation, name, "is not allowed"))
+			}
+		default:
+			v.Errors = append(v.Errors, fieldError{
+				Field:   pointerField(unit.InstanceLocation),
+				Pointer: unit.InstanceLocation,
+				Message: kindMessage(unit.Error.Kind, unit.Error.String()),
+			})
+		}
+	}
+	return v.err()
+}
+
+// kindMessage words the common schema violations the way validateMessage
+// does, falling back to the validator's own message for the rest.
+func kindMessage(k jsonschema.ErrorKind, fallback string) string {
+	switch k := k.(type) {
+	case *kind.Type:
+		want := make([]string, len(k.Want))
+		for i, t := range k.Want {
+			want[i] = withArticle(t)
+		}
+		return "must be " + strings.Join(want, " or ")
+	case *kind.MinLength:
+		if k.Want == 1 {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("is %d characters, minimum is %d", k.Got, k.Want)
+	case *kind.MaxLength:
+		return fmt.Sprintf("is %d characters, maximum is %d", k.Got, k.Want)
+	}
+	return fallback
+}
+
+// withArticle prefixes a JSON type name with "a" or "an".
+func withArticle(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	case "null":
+		return typ
+	}
+	return "a " + typ
+}
+
+// propertyError reports a problem with the property name of the object at
+// the JSON pointer parent.
+func propertyError(parent, name, msg string) fieldError {
+	escaped := strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+	return fieldError{Field: name, Pointer: parent + "/" + escaped, Message: msg}
+}
+
+// pointerField returns the last segment of a JSON pointer, which names the
+// offending field, or "" for the document root.
+func pointerField(ptr string) string {
+	i := strings.LastIndexByte(ptr, '/')
+	if i < 0 {
+		return ""
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(ptr[i+1:])
+}
+
+// decodeValidated decodes a single JSON value from the request body like
+// decodeJSONBody, then checks it against sch before unmarshaling it into
+// dst. Schema violations are returned as a *validationError.
+func decodeValidated(w http.ResponseWriter, r *http.Request, sch *jsonschema.Schema, dst any, maxBytes int64) error {
+	var raw json.RawMessage
+	if err := decodeJSONBody(w, r, &raw, maxBytes); err != nil {
+		return err
+	}
+	if err := validateJSON(sch, raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
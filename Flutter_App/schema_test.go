@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// compileSchema compiles an inline schema for tests.
+func compileSchema(t *testing.T, schema string) *jsonschema.Schema {
+	t.Helper()
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("test.json", doc); err != nil {
+		t.Fatal(err)
+	}
+	return c.MustCompile("test.json")
+}
+
+// schemaErrors validates raw against sch and returns each problem as
+// "pointer: message", sorted.
+func schemaErrors(t *testing.T, sch *jsonschema.Schema, raw string) []string {
+	t.Helper()
+	err := validateJSON(sch, []byte(raw))
+	if err == nil {
+		return nil
+	}
+	var v *validationError
+	if !errors.As(err, &v) {
+		t.Fatalf("validateJSON(%s) = %v, want a *validationError", raw, err)
+	}
+	var got []string
+	for _, fe := range v.Errors {
+		got = append(got, fe.Pointer+": "+fe.Message)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestMessageSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"valid", `{"text":"hi"}`, nil},
+		{"empty text left to validateMessage", `{"text":""}`, nil},
+		{"extra field", `{"text":"hi","colour":"red"}`, []string{"/colour: is not allowed"}},
+		{"wrong type", `{"text":42}`, []string{"/text: must be a string"}},
+		{"missing", `{}`, []string{"/text: is required"}},
+		{"not an object", `["hi"]`, []string{": must be an object"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaErrors(t, messageSchema, tt.raw); !slices.Equal(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaErrorWording(t *testing.T) {
+	sch := compileSchema(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"code": {"type": "string", "minLength": 3, "maxLength": 5},
+			"a/b":  {"type": ["integer", "null"]}
+		}
+	}`)
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`{"name":""}`, []string{"/name: must not be empty"}},
+		{`{"code":"ab"}`, []string{"/code: is 2 characters, minimum is 3"}},
+		{`{"code":"abcdef"}`, []string{"/code: is 6 characters, maximum is 5"}},
+		{`{"a/b":"x"}`, []string{"/a~1b: must be null or an integer"}},
+	}
+	for _, tt := range tests {
+		if got := schemaErrors(t, sch, tt.raw); !slices.Equal(got, tt.want) {
+			t.Errorf("validating %s: errors = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
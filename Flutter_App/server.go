@@ -1,45 +1,686 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
-type Message struct {
-	Text string `json:"text"`
+// server holds the configuration and state shared by the HTTP handlers.
+type server struct {
+	cfg     Config
+	checks  *healthRegistry
+	store   MessageStore
+	hub     *hub
+	auth    *authenticator
+	limiter *rateLimiter
+	metrics *metrics
+	// concurrency caps the requests served at once across all routes.
+	concurrency *concurrencyLimiter
+	throughput  *throughput
+	// logLevel is the level of the process logger, adjustable at runtime
+	// through /admin/loglevel when auth tokens are configured.
+	logLevel *slog.LevelVar
+	// stop is closed when a graceful shutdown has been requested; stopping
+	// guards it against a second close.
+	stop     chan struct{}
+	stopping atomic.Bool
+	// idempotency caches responses to POST /echo by Idempotency-Key.
+	idempotency *idempotencyCache
+	// writes counts successful store writes; with etagEpoch it versions
+	// the ETags on GET /messages.
+	writes    atomic.Uint64
+	etagEpoch string
+	expiry    expiryMark
+	// sinks forwards stored messages to external systems.
+	sinks *sinkDispatcher
 }
 
-func hello(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+func newServer(cfg Config, store MessageStore, auth *authenticator) *server {
+	s := &server{
+		cfg:     cfg,
+		checks:  newHealthRegistry(),
+		store:   store,
+		hub:     newHub(),
+		auth:    auth,
+		limiter: newRateLimiter(cfg.RateLimit, cfg.RateBurst, cfg.TrustProxy),
+		metrics: newMetrics(),
 
-	if r.Method == http.MethodPost {
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusBadRequest)
+		idempotency: newIdempotencyCache(cfg.IdempotencyTTL, cfg.MaxBodyBytes),
+		concurrency: newConcurrencyLimiter(cfg.MaxConcurrent, cfg.ConcurrencyWait),
+		throughput:  newThroughput(cfg.StatsInterval),
+		logLevel:    new(slog.LevelVar),
+		stop:        make(chan struct{}),
+		etagEpoch:   newETagEpoch(),
+	}
+	var sinks []Sink
+	if cfg.WebhookURL != "" {
+		sinks = append(sinks, newWebhookSink(cfg.WebhookURL, cfg.WebhookTimeout))
+	}
+	s.sinks = newSinkDispatcher(cfg.SinkWorkers, cfg.SinkQueueSize, sinks...)
+	s.logLevel.Set(cfg.LogLevel)
+	s.metrics.observeConcurrency(s.concurrency)
+	s.metrics.observeDraining(s.stopping.Load)
+	s.metrics.observeHub(s.hub)
+	return s
+}
+
+// routeTable lists every API route. Routes run under cfg.HandlerTimeout
+// unless they set their own; streaming routes are not timed at all.
+func (s *server) routeTable() []route {
+	timed := WithTimeout(s.cfg.HandlerTimeout)
+	routes := []route{
+		{"GET", "/{$}", timed(http.HandlerFunc(hello))},
+		{"POST", "/echo", timed(s.limiter.limit(s.auth.require(s.idempotency.wrap(s.echo))))},
+		{"POST", "/echo/stream", s.limiter.limit(s.auth.require(s.echoStream))},
+		{"POST", "/echo/batch", timed(s.limiter.limit(s.auth.require(s.echoBatch)))},
+		{"POST", "/upload", WithTimeout(s.cfg.UploadTimeout)(s.limiter.limit(s.auth.require(s.upload)))},
+		{"GET", "/messages", timed(http.HandlerFunc(s.listMessages))},
+		{"DELETE", "/messages", timed(s.auth.require(s.clearMessages))},
+		{"GET", "/messages/search", timed(http.HandlerFunc(s.searchMessages))},
+		{"GET", "/messages/{id}", timed(http.HandlerFunc(s.getMessage))},
+		{"GET", "/ws", http.HandlerFunc(s.websocket)},
+		{"GET", "/events", http.HandlerFunc(s.events)},
+		{"GET", "/health", timed(http.HandlerFunc(s.health))},
+		{"GET", "/ready", timed(http.HandlerFunc(s.ready))},
+		{"GET", "/metrics", timed(s.metrics.handler())},
+		{"GET", "/stats", timed(http.HandlerFunc(s.stats))},
+		{"GET", "/version", timed(http.HandlerFunc(version))},
+		{"GET", "/openapi.json", timed(http.HandlerFunc(s.openAPI))},
+	}
+	// Without tokens auth.require lets everyone through, and debug logging
+	// includes request bodies, so the admin routes need tokens.
+	if s.cfg.authConfigured() {
+		routes = append(routes,
+			route{"GET", "/admin/loglevel", timed(s.auth.require(s.getLogLevel))},
+			route{"POST", "/admin/loglevel", timed(s.auth.require(s.setLogLevel))},
+		)
+	}
+	if s.cfg.AdminShutdown {
+		routes = append(routes, route{"POST", "/admin/shutdown", timed(s.auth.require(s.adminShutdown))})
+	}
+	return routes
+}
+
+// handler returns the routes wrapped in the full middleware chain, logging
+// requests to logger.
+func (s *server) handler(logger *slog.Logger) http.Handler {
+	return Chain(s.routes(),
+		requestID,
+		func(h http.Handler) http.Handler { return logRequests(logger, h) },
+		s.metrics.instrument,
+		recoverPanics,
+		func(h http.Handler) http.Handler { return compress(s.cfg.GzipMinSize, h) },
+		func(h http.Handler) http.Handler { return cors(s.cfg.CORSOrigins, h) },
+		decompress,
+		s.normalizeSlashes,
+		s.mount,
+		s.injectDelay,
+		s.concurrency.limit,
+		recordRoute,
+	)
+}
+
+// routes registers the route table on a dedicated mux rather than the
+// package-level default.
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	handle(mux, s.routeTable())
+	if s.cfg.EnablePprof {
+		// Not timed: CPU profiles and traces run for as long as requested.
+		// net/http/pprof moves the write deadline past WriteTimeout itself,
+		// through the ResponseController every wrapper in the chain
+		// unwraps to.
+		mux.Handle(pprofPath, s.auth.require(pprofHandler().ServeHTTP))
+	}
+	mux.HandleFunc("/", notFound)
+	return mux
+}
+
+// mount places the API routes under cfg.BasePath, stripping the prefix so
+// the handlers never see it. With ProbesAtRoot set, /health, /ready and
+// /metrics are also served at the root for orchestrators that probe the
+// bare path.
+func (s *server) mount(api http.Handler) http.Handler {
+	if s.cfg.BasePath == "" {
+		return api
+	}
+
+	root := http.NewServeMux()
+	root.Handle(s.cfg.BasePath+"/", http.StripPrefix(s.cfg.BasePath, api))
+	root.HandleFunc(s.cfg.BasePath, func(w http.ResponseWriter, r *http.Request) {
+		s.redirect(w, r, s.cfg.BasePath+"/", http.StatusMovedPermanently)
+	})
+	if s.cfg.ProbesAtRoot {
+		// These bypass the API mux and its recordRoute, so record their
+		// pattern here or the metrics would label them unmatched.
+		handle(root, []route{
+			{"GET", "/health", recordRoute(http.HandlerFunc(s.health))},
+			{"GET", "/ready", recordRoute(http.HandlerFunc(s.ready))},
+			{"GET", "/metrics", recordRoute(s.metrics.handler())},
+		})
+	}
+	root.HandleFunc("/", notFound)
+	return root
+}
+
+func (s *server) echo(w http.ResponseWriter, r *http.Request) {
+	transform, err := lookupTransform(r.URL.Query().Get("transform"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dryRun, err := parseDryRun(r.URL.Query().Get("dry_run"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ttl, err := parseTTL(r.URL.Query().Get("ttl_seconds"), s.cfg.MessageTTL)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	withMeta, err := parseMeta(r.URL.Query().Get("meta"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var msg Message
+	if err := decodeValidated(w, r, messageSchema, &msg, s.cfg.MaxBodyBytes); err != nil {
+		var v *validationError
+		if errors.As(err, &v) {
+			writeValidationError(w, v)
 			return
 		}
+		writeRequestError(w, err)
+		return
+	}
+
+	msg.Text = transform(msg.Text)
+	if err := validateMessage(msg, s.cfg.MaxMessageLength); err != nil {
+		writeValidationError(w, err.(*validationError))
+		return
+	}
+	msg = newMessage(msg.Text, ttl)
+	resp := echoResponse{DryRun: dryRun}
+	if withMeta {
+		resp.Meta = computeMeta(msg.Text)
+	}
+	if dryRun {
+		resp.Message = msg
+		respond(w, r, resp)
+		return
+	}
 
-		var msg Message
-		err = json.Unmarshal(body, &msg)
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	msg, err = s.storeMessage(ctx, msg)
+	if err != nil {
+		writeStoreError(w, ctx, err, "saving message", "Error storing message")
+		return
+	}
+	if n, err := s.store.Count(ctx); err == nil {
+		w.Header().Set("X-Message-Count", strconv.FormatInt(n, 10))
+	} else {
+		slog.WarnContext(ctx, "counting messages", "error", err)
+	}
+	resp.Message = msg
+	respond(w, r, resp) // Echo the message back
+}
+
+// parseDryRun reads the dry_run query parameter, which must be absent,
+// "true" or "false".
+func parseDryRun(v string) (bool, error) {
+	switch v {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, errors.New("dry_run must be true or false")
+}
+
+// storeMessage saves msg, built by newMessage, and publishes it to
+// streaming clients.
+func (s *server) storeMessage(ctx context.Context, msg Message) (Message, error) {
+	id, err := s.store.Save(ctx, msg)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.ID = id
+	s.noteExpiry(msg.ExpiresAt)
+	s.writes.Add(1)
+	s.throughput.record()
+
+	slog.InfoContext(ctx, "received message", "id", msg.ID, "text", msg.Text)
+	s.hub.publish(msg)
+	s.sinks.enqueue(msg)
+	return msg, nil
+}
+
+// batchResult reports the outcome for one item of POST /echo/batch, at the
+// same index as the item in the request.
+type batchResult struct {
+	Status  string       `json:"status"`
+	Message *Message     `json:"message,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Errors  []fieldError `json:"errors,omitempty"`
+}
+
+// echoBatch stores each message of a JSON array independently, so one bad
+// item does not fail the rest.
+func (s *server) echoBatch(w http.ResponseWriter, r *http.Request) {
+	transform, err := lookupTransform(r.URL.Query().Get("transform"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Items are decoded one at a time so that one malformed item is
+	// reported against its index instead of failing the whole batch.
+	var batch []json.RawMessage
+	if err := decodeJSONBody(w, r, &batch, s.cfg.MaxBodyBytes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(batch) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "batch must not be empty")
+		return
+	}
+	if len(batch) > s.cfg.MaxBatchSize {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Sprintf("batch must contain at most %d messages", s.cfg.MaxBatchSize))
+		return
+	}
+
+	results := make([]batchResult, len(batch))
+	for i, raw := range batch {
+		var item Message
+		err := validateJSON(messageSchema, raw)
+		if err == nil {
+			err = json.Unmarshal(raw, &item)
+		}
+		if err == nil {
+			item.Text = transform(item.Text)
+			err = validateMessage(item, s.cfg.MaxMessageLength)
+		}
 		if err != nil {
-			http.Error(w, "Error unmarshalling JSON", http.StatusBadRequest)
+			var itemErr *validationError
+			if !errors.As(err, &itemErr) {
+				results[i] = batchResult{Status: "error", Error: "Invalid message"}
+				continue
+			}
+			var v validationError
+			v.addItem(i, itemErr)
+			results[i] = batchResult{Status: "error", Error: "Validation failed", Errors: v.Errors}
+			continue
+		}
+
+		ctx, cancel := s.storeContext(r)
+		msg, err := s.storeMessage(ctx, newMessage(item.Text, s.cfg.MessageTTL))
+		if errors.Is(err, errStoreUnavailable) {
+			cancel()
+			writeJSONError(w, http.StatusServiceUnavailable, "Storage unavailable")
 			return
 		}
+		if err != nil {
+			slog.ErrorContext(ctx, "saving batch message", "index", i, "error", err)
+			results[i] = batchResult{Status: "error", Error: "Error storing message"}
+		} else {
+			results[i] = batchResult{Status: "ok", Message: &msg}
+		}
+		cancel()
+	}
+	writeJSON(w, http.StatusOK, results)
+}
 
-		fmt.Printf("Received message: %s\n", msg.Text)
-		json.NewEncoder(w).Encode(msg) // Echo the message back
+type messagePage struct {
+	Messages []Message `json:"messages"`
+	Total    int64     `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+func (s *server) listMessages(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	msg := Message{Text: "Hello from Go!"}
-	json.NewEncoder(w).Encode(msg)
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	nextExpiry, err := s.nextExpiry(ctx)
+	if err != nil {
+		writeStoreError(w, ctx, err, "finding next message expiry", "Error listing messages")
+		return
+	}
+
+	// The ETag is taken before reading, so a write or expiry that races
+	// with this request can only make the ETag stale, never newer than the
+	// data.
+	etag := s.messagesETag(limit, offset, nextExpiry)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		setValidators(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	messages, total, err := s.store.List(ctx, limit, offset)
+	if err != nil {
+		writeStoreError(w, ctx, err, "listing messages", "Error listing messages")
+		return
+	}
+
+	setValidators(w, etag)
+	writeJSON(w, http.StatusOK, messagePage{
+		Messages: messages,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+
+// searchMessages returns the messages whose text contains the q query
+// parameter, ignoring case, in the same envelope as listMessages.
+func (s *server) searchMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query parameter q must not be empty")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	messages, total, err := s.store.Search(ctx, query, limit, offset)
+	if err != nil {
+		writeStoreError(w, ctx, err, "searching messages", "Error searching messages")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, messagePage{
+		Messages: messages,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+
+func (s *server) getMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "Message ID must be a positive integer")
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	msg, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("Message %d not found", id))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, ctx, err, "getting message", "Error getting message")
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func (s *server) clearMessages(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	n, err := s.store.Clear(ctx)
+	if err != nil {
+		writeStoreError(w, ctx, err, "clearing messages", "Error clearing messages")
+		return
+	}
+	s.writes.Add(1)
+	slog.InfoContext(r.Context(), "cleared messages", "deleted", n)
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": n})
+}
+
+// storeContext bounds a store call by cfg.StoreTimeout, so a stalled
+// backend fails the request instead of hanging it.
+func (s *server) storeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), s.cfg.StoreTimeout)
+}
+
+// writeStoreError logs a failed store call and reports it with clientMsg, or
+// with 503 if the store is unavailable or the call ran past its deadline. The deadline is read from ctx
+// because drivers do not reliably wrap context.DeadlineExceeded.
+func writeStoreError(w http.ResponseWriter, ctx context.Context, err error, logMsg, clientMsg string) {
+	if errors.Is(err, errStoreUnavailable) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Storage unavailable")
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.WarnContext(ctx, logMsg+" timed out", "error", err)
+		writeJSONError(w, http.StatusServiceUnavailable, "Storage timed out")
+		return
+	}
+	slog.ErrorContext(ctx, logMsg, "error", err)
+	writeJSONError(w, http.StatusInternalServerError, clientMsg)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, http.StatusNotFound, "Not found")
+}
+
+// newHTTPServer builds the http.Server with explicit timeouts. The zero-value
+// server used by http.ListenAndServe never times out, which lets slow or
+// idle clients hold connections open indefinitely. With EnableH2C the
+// server also accepts HTTP/2 without TLS, alongside HTTP/1.1. That is done
+// by net/http itself rather than by wrapping the handler, so h2c connections
+// stay tracked by the server and are drained by Shutdown like any other.
+func newHTTPServer(cfg Config, h http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:    cfg.Addr,
+		Handler: h,
+		// Bounds how long a client may take to send its headers. This is the
+		// main defence against slowloris, where an attacker opens many
+		// connections and trickles header bytes to keep them alive.
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		// Bounds reading the whole request, body included, so a slow upload
+		// cannot tie up a handler goroutine forever.
+		ReadTimeout: cfg.ReadTimeout,
+		// Bounds the time from the end of the header read to the end of the
+		// response write, so clients that stop reading are eventually dropped.
+		WriteTimeout: cfg.WriteTimeout,
+		// Bounds how long a keep-alive connection may wait for its next
+		// request before being closed, limiting idle file descriptors. It
+		// applies to HTTP/2 connections, h2c included, as well.
+		IdleTimeout: cfg.IdleTimeout,
+		TLSConfig:   tlsConfig(),
+	}
+	if cfg.EnableH2C {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = &protocols
+	}
+	return srv
+}
+
+// tlsConfig requires TLS 1.2 or later and restricts TLS 1.2 to forward-secret
+// AEAD cipher suites. TLS 1.3 suites are not configurable and are all safe.
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// listen opens the TCP listener for cfg.Addr, with SO_REUSEPORT when
+// cfg.ReusePort is set.
+func listen(cfg Config) (net.Listener, error) {
+	var lc net.ListenConfig
+	if cfg.ReusePort {
+		lc.Control = reusePortControl
+	}
+	return lc.Listen(context.Background(), "tcp", cfg.Addr)
+}
+
+// requestShutdown starts a graceful shutdown. It reports false if one was
+// already under way.
+func (s *server) requestShutdown() bool {
+	if !s.stopping.CompareAndSwap(false, true) {
+		return false
+	}
+	close(s.stop)
+	return true
+}
+
+// waitForShutdown blocks until SIGINT or SIGTERM is received or app requests
+// a shutdown, waits out the configured drain delay, then drains streaming
+// clients and in-flight requests. If the drain takes longer than timeout the
+// remaining connections are closed forcibly.
+func waitForShutdown(srv *http.Server, app *server, timeout time.Duration) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case s := <-sig:
+		slog.Info("shutting down", "signal", s.String())
+		app.requestShutdown()
+	case <-app.stop:
+		slog.Info("shutting down", "reason", "admin request")
+	}
+	h := app.hub
+
+	// /ready has been failing since requestShutdown; keep serving normally
+	// until load balancers notice and stop sending new requests.
+	if d := app.cfg.DrainDelay; d > 0 {
+		slog.Info("waiting for load balancers before draining", "drain_delay", d.String())
+		time.Sleep(d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Streams go first: event streams would otherwise hold Shutdown open
+	// until the timeout, and Shutdown does not track hijacked WebSocket
+	// connections at all.
+	graceCtx, cancelGrace := context.WithTimeout(ctx, streamDrainGrace)
+	if err := h.drain(graceCtx); err != nil {
+		slog.Warn("streaming clients did not disconnect in time", "grace", streamDrainGrace.String())
+	}
+	cancelGrace()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("graceful shutdown timed out, forcing close", "timeout", timeout.String(), "error", err)
+		srv.Close()
+		return
+	}
+	slog.Info("server shut down cleanly")
 }
 
 func main() {
-	http.HandleFunc("/", hello)
-	fmt.Println("Server listening on port 8080")
-	http.ListenAndServe(":8080", nil)
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(2)
+	}
+
+	startTime = time.Now()
+
+	level := new(slog.LevelVar)
+	level.Set(cfg.LogLevel)
+	logger := slog.New(contextHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})})
+	slog.SetDefault(logger)
+
+	ln, err := listen(cfg)
+	if err != nil {
+		slog.Error("failed to listen", "addr", cfg.Addr, "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A store that cannot be opened leaves the server running degraded while
+	// it keeps retrying in the background.
+	store := &degradableStore{}
+	if backend, err := openStore(ctx, cfg); err != nil {
+		slog.Warn("message store unavailable, starting degraded", "storage", cfg.Storage, "error", err)
+		store.lastErr = err
+		go store.reconnect(ctx, func(ctx context.Context) (MessageStore, error) {
+			return openStore(ctx, cfg)
+		})
+	} else {
+		store.set(backend)
+	}
+	defer store.Close()
+
+	auth, err := newAuthenticator(cfg.AuthTokens, cfg.AuthTokensFile)
+	if err != nil {
+		slog.Error("failed to load auth tokens", "error", err)
+		os.Exit(1)
+	}
+	if !auth.enabled() {
+		slog.Warn("no auth tokens configured, write endpoints are unauthenticated")
+	}
+	if cfg.AuthTokensFile != "" {
+		if err := auth.watch(ctx); err != nil {
+			slog.Warn("auth tokens file will not be reloaded", "error", err)
+		}
+	}
+
+	app := newServer(cfg, store, auth)
+	app.logLevel = level
+	app.checks.register("storage", store.check)
+	go app.hub.run(ctx)
+	go app.limiter.sweep(ctx)
+	go app.idempotency.sweep(ctx)
+	go app.throughput.run(ctx)
+	go app.sinks.run(ctx)
+	go app.purgeExpired(ctx)
+
+	srv := newHTTPServer(cfg, app.handler(logger))
+
+	done := make(chan struct{})
+	go func() {
+		waitForShutdown(srv, app, cfg.ShutdownTimeout)
+		close(done)
+	}()
+
+	if cfg.TLSEnabled() {
+		slog.Info("server listening", "addr", ln.Addr().String(), "tls", true)
+		err = srv.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		slog.Info("server listening", "addr", ln.Addr().String(), "tls", false, "h2c", cfg.EnableH2C)
+		err = srv.Serve(ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		slog.Error("server error", "error", err)
+		os.Exit(1)
+	}
+	<-done
 }
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Handlers log through the default logger; keep test output readable.
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	os.Exit(m.Run())
+}
+
+// testConfig returns the defaults with the rate limit lifted, so tests can
+// post as many messages as they need.
+func testConfig() Config {
+	cfg := defaultConfig()
+	cfg.RateLimit = 1e6
+	cfg.RateBurst = 1e6
+	return cfg
+}
+
+// newTestServer returns a server over a fresh memory store, with the
+// background workers main starts running until the test ends.
+func newTestServer(t *testing.T, cfg Config) *server {
+	t.Helper()
+	return newTestServerWithStore(t, cfg, newMemoryStore(cfg.MaxStoredMessages))
+}
+
+// newTestServerWithStore is newTestServer over store. A nil store leaves
+// the server degraded, as when the backend cannot be opened at startup.
+func newTestServerWithStore(t *testing.T, cfg Config, store MessageStore) *server {
+	t.Helper()
+	auth, err := newAuthenticator(cfg.AuthTokens, cfg.AuthTokensFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	degradable := &degradableStore{}
+	if store != nil {
+		degradable.set(store)
+	}
+	s := newServer(cfg, degradable, auth)
+	s.checks.register("storage", degradable.check)
+
+	ctx := t.Context()
+	go s.hub.run(ctx)
+	go s.throughput.run(ctx)
+	return s
+}
+
+// serve returns an httptest.Server running s behind the full middleware
+// chain.
+func serve(t *testing.T, s *server) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(s.handler(slog.Default()))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// closingClient returns a client that closes each connection once its
+// response is read. Tests that shut the server down use it, so Shutdown is
+// not left waiting for a kept-alive connection to go idle.
+func closingClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+}
+
+// do sends a request to h and returns the recorded response. A non-empty
+// body is sent as JSON; header lists extra header names and values.
+func do(t *testing.T, h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode unmarshals the body of rec into a value of type T.
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// postMessage stores a message with the given text through POST /echo.
+func postMessage(t *testing.T, h http.Handler, text string) Message {
+	t.Helper()
+	body, _ := json.Marshal(Message{Text: text})
+	rec := do(t, h, "POST", "/echo", string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /echo %q = %d: %s", text, rec.Code, rec.Body)
+	}
+	return decode[Message](t, rec)
+}
+
+func TestListenAddrFromEnv(t *testing.T) {
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:0")
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "127.0.0.1:0" {
+		t.Fatalf("Addr = %q, want the LISTEN_ADDR value", cfg.Addr)
+	}
+
+	ln, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(cfg, newTestServer(t, cfg).handler(slog.Default()))
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	res, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET / = %d, want 200", res.StatusCode)
+	}
+}
+
+func TestReadHeaderTimeoutClosesSlowClients(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.ReadHeaderTimeout = 200 * time.Millisecond
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(cfg, newTestServer(t, cfg).handler(slog.Default()))
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start a request but never finish its headers.
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(5 * time.Second))
+	_, err = io.Copy(io.Discard, conn)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("server kept the connection open past the read header timeout")
+	}
+	if elapsed := time.Since(start); elapsed < cfg.ReadHeaderTimeout/2 {
+		t.Fatalf("connection closed after %s, before the read header timeout", elapsed)
+	}
+}
+
+func TestListMessagesInOrder(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	texts := []string{"first", "second", "third"}
+	for _, text := range texts {
+		postMessage(t, h, text)
+	}
+
+	rec := do(t, h, "GET", "/messages", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	page := decode[messagePage](t, rec)
+	if len(page.Messages) != len(texts) {
+		t.Fatalf("got %d messages, want %d", len(page.Messages), len(texts))
+	}
+	for i, msg := range page.Messages {
+		if msg.Text != texts[i] || msg.ID != int64(i+1) {
+			t.Errorf("message %d = {%d %q}, want {%d %q}", i, msg.ID, msg.Text, i+1, texts[i])
+		}
+	}
+}
+
+// writeSelfSignedCert generates a certificate for 127.0.0.1, writes it and
+// its key as PEM files, and returns their paths with a pool trusting it.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	return certFile, keyFile, roots
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, roots := writeSelfSignedCert(t)
+	cfg := testConfig()
+	cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(cfg, newTestServer(t, cfg).handler(slog.Default()))
+	go srv.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+	t.Cleanup(func() { srv.Close() })
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	res, err := client.Get("https://" + ln.Addr().String() + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.TLS == nil {
+		t.Fatalf("GET over HTTPS = %d (TLS %v), want 200 over TLS", res.StatusCode, res.TLS != nil)
+	}
+	if res.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("negotiated TLS version %x, want 1.2 or later", res.TLS.Version)
+	}
+}
+
+func TestServeH2C(t *testing.T) {
+	cfg := testConfig()
+	cfg.EnableH2C = true
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(cfg, newTestServer(t, cfg).handler(slog.Default()))
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	url := "http://" + ln.Addr().String() + "/version"
+
+	// Prior-knowledge h2c: HTTP/2 frames straight over the TCP connection.
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	h2c := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	res, err := h2c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.ProtoMajor != 2 {
+		t.Fatalf("GET over h2c = %d %s, want 200 over HTTP/2", res.StatusCode, res.Proto)
+	}
+
+	res, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.ProtoMajor != 1 {
+		t.Errorf("GET over HTTP/1.1 = %d %s, want 200 over HTTP/1.1 alongside h2c", res.StatusCode, res.Proto)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	t.Run("without prefix", func(t *testing.T) {
+		h := newTestServer(t, testConfig()).handler(slog.Default())
+		if rec := do(t, h, "GET", "/messages", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET /messages = %d, want 200", rec.Code)
+		}
+		if rec := do(t, h, "GET", "/api/messages", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET /api/messages = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("with prefix", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.BasePath = "/api"
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		if rec := do(t, h, "POST", "/api/echo", `{"text":"hi"}`); rec.Code != http.StatusOK {
+			t.Errorf("POST /api/echo = %d, want 200", rec.Code)
+		}
+		if rec := do(t, h, "GET", "/api/messages", ""); rec.Code != http.StatusOK || decode[messagePage](t, rec).Total != 1 {
+			t.Errorf("GET /api/messages = %d %s, want the posted message", rec.Code, rec.Body)
+		}
+		for _, path := range []string{"/messages", "/health"} {
+			if rec := do(t, h, "GET", path, ""); rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want 404 outside the prefix", path, rec.Code)
+			}
+		}
+		rec := do(t, h, "GET", "/api", "")
+		if loc := rec.Header().Get("Location"); rec.Code != http.StatusMovedPermanently || loc != "/api/" {
+			t.Errorf("GET /api = %d to %q, want a 301 to /api/", rec.Code, loc)
+		}
+	})
+
+	t.Run("probes at root", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.BasePath = "/api"
+		cfg.ProbesAtRoot = true
+		h := newTestServer(t, cfg).handler(slog.Default())
+		for _, path := range []string{"/health", "/ready", "/api/health"} {
+			if rec := do(t, h, "GET", path, ""); rec.Code != http.StatusOK {
+				t.Errorf("GET %s = %d, want 200", path, rec.Code)
+			}
+		}
+	})
+}
+
+func TestTrailingSlashRedirectStaysOnHost(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for _, target := range []string{"//evil.com/", `/\evil.com/`} {
+		rec := do(t, h, "GET", target, "")
+		if rec.Code != http.StatusMovedPermanently {
+			t.Fatalf("GET %s = %d, want 301", target, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != "/evil.com" {
+			t.Errorf("GET %s redirects to %q, want /evil.com on this host", target, got)
+		}
+	}
+}
+
+func TestClearMessages(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	for _, text := range []string{"a", "b", "c"} {
+		postMessage(t, h, text)
+	}
+
+	rec := do(t, h, "DELETE", "/messages", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /messages = %d, want 200", rec.Code)
+	}
+	if got := decode[map[string]int](t, rec); got["deleted"] != 3 {
+		t.Errorf("deleted = %d, want 3", got["deleted"])
+	}
+	page := decode[messagePage](t, do(t, h, "GET", "/messages", ""))
+	if len(page.Messages) != 0 || page.Total != 0 {
+		t.Errorf("GET /messages after clearing = %+v, want an empty list", page)
+	}
+}
+
+func TestGetMessage(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	posted := postMessage(t, h, "find me")
+
+	rec := do(t, h, "GET", "/messages/1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /messages/1 = %d, want 200", rec.Code)
+	}
+	if got := decode[Message](t, rec); got.ID != posted.ID || got.Text != "find me" {
+		t.Errorf("GET /messages/1 = %+v, want %+v", got, posted)
+	}
+
+	rec = do(t, h, "GET", "/messages/2", "")
+	if rec.Code != http.StatusNotFound || decode[errorResponse](t, rec).Error != "Message 2 not found" {
+		t.Errorf("GET /messages/2 = %d %s, want 404", rec.Code, rec.Body)
+	}
+
+	for _, id := range []string{"abc", "0", "-1", "1.5"} {
+		if rec := do(t, h, "GET", "/messages/"+id, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /messages/%s = %d, want 400", id, rec.Code)
+		}
+	}
+}
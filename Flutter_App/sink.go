@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Sink receives every message after it has been stored, for forwarding to
+// an external system such as a webhook or a message queue.
+type Sink interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// sinkDispatcher hands stored messages to the registered sinks from a fixed
+// pool of workers, so a slow or failing sink never delays the response to
+// the client. Messages that arrive while the queue is full are dropped and
+// logged rather than blocking the request.
+type sinkDispatcher struct {
+	sinks   []Sink
+	queue   chan Message
+	workers int
+}
+
+func newSinkDispatcher(workers, queueSize int, sinks ...Sink) *sinkDispatcher {
+	return &sinkDispatcher{
+		sinks:   sinks,
+		queue:   make(chan Message, queueSize),
+		workers: workers,
+	}
+}
+
+// enqueue schedules msg for delivery to every sink without blocking.
+func (d *sinkDispatcher) enqueue(msg Message) {
+	if len(d.sinks) == 0 {
+		return
+	}
+	select {
+	case d.queue <- msg:
+	default:
+		slog.Warn("sink queue full, dropping message", "id", msg.ID, "queue_size", cap(d.queue))
+	}
+}
+
+// run starts the workers and blocks until ctx is cancelled.
+func (d *sinkDispatcher) run(ctx context.Context) {
+	if len(d.sinks) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	for range d.workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-d.queue:
+					d.deliver(ctx, msg)
+				}
+			}
+		}()
+	}
+	for range d.workers {
+		<-done
+	}
+}
+
+func (d *sinkDispatcher) deliver(ctx context.Context, msg Message) {
+	for _, sink := range d.sinks {
+		if err := sink.Publish(ctx, msg); err != nil {
+			slog.Warn("sink publish failed", "sink", fmt.Sprintf("%T", sink), "id", msg.ID, "error", err)
+		}
+	}
+}
+
+// webhookSink POSTs each message as JSON to a fixed URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookSink(url string, timeout time.Duration) *webhookSink {
+	return &webhookSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Publish sends msg and treats any non-2xx response as a failure.
+func (s *webhookSink) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
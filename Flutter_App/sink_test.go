@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// chanSink delivers every published message on a channel.
+type chanSink chan Message
+
+func (s chanSink) Publish(ctx context.Context, msg Message) error {
+	s <- msg
+	return nil
+}
+
+// stuckSink blocks every publish until its context is done.
+type stuckSink struct{}
+
+func (stuckSink) Publish(ctx context.Context, msg Message) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSinksReceiveStoredMessages(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	received := make(chanSink, 10)
+	s.sinks = newSinkDispatcher(1, 10, received, stuckSink{})
+	go s.sinks.run(t.Context())
+	h := s.handler(slog.Default())
+
+	start := time.Now()
+	msg := postMessage(t, h, "forward me")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("POST /echo took %s, want it not to wait for sinks", elapsed)
+	}
+
+	select {
+	case got := <-received:
+		if got != msg {
+			t.Errorf("sink got %+v, want %+v", got, msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sink never received the message")
+	}
+}
+
+func TestSlowSinkDoesNotBlockResponses(t *testing.T) {
+	s := newTestServer(t, testConfig())
+	// One worker and a one-message queue: the first message wedges the
+	// worker, the second fills the queue and the rest are dropped.
+	s.sinks = newSinkDispatcher(1, 1, stuckSink{})
+	go s.sinks.run(t.Context())
+	h := s.handler(slog.Default())
+
+	start := time.Now()
+	for range 5 {
+		postMessage(t, h, "into the void")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("posting past a stuck sink took %s, want no waiting", elapsed)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	got := make(chan Message, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&msg)
+		got <- msg
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(hook.Close)
+	sink := newWebhookSink(hook.URL, time.Second)
+
+	msg := Message{ID: 3, Text: "hooked"}
+	if err := sink.Publish(t.Context(), msg); err != nil {
+		t.Fatalf("Publish = %v, want success", err)
+	}
+	if m := <-got; m.ID != 3 || m.Text != "hooked" {
+		t.Errorf("webhook received %+v, want %+v", m, msg)
+	}
+
+	failing := newWebhookSink(hook.URL+"/failing", time.Second)
+	if err := failing.Publish(t.Context(), msg); err == nil {
+		t.Error("Publish succeeded on a 502 from the webhook")
+	}
+	<-got
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// normalizeSlashes makes a trailing slash on a request path optional, since
+// ServeMux would otherwise treat /messages/ as a different, unknown path.
+// With cfg.TrailingSlash set to "redirect", GET and HEAD requests are sent a
+// 301 to the canonical path so clients and caches learn it; every other
+// request, and all requests in "rewrite" mode, is served under the canonical
+// path directly, so POST /echo/ works without the client resending its body.
+// The root, the base path root and the pprof index keep their slash.
+func (s *server) normalizeSlashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasSuffix(path, "/") || path == "/" || path == s.cfg.BasePath+"/" || path == s.cfg.BasePath+pprofPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		canonical := canonicalPath(path)
+
+		if s.cfg.TrailingSlash == "redirect" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			s.redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = canonical
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = canonicalPath(r.URL.RawPath)
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// canonicalPath strips the trailing slashes from p and collapses any run of
+// slashes and backslashes it starts with into one slash. Redirecting to
+// //evil.com or /\evil.com, which browsers both read as a link to another
+// host, would otherwise make this an open redirect.
+func canonicalPath(p string) string {
+	return "/" + strings.TrimLeft(strings.TrimRight(p, "/"), `/\`)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	t.Run("redirect", func(t *testing.T) {
+		h := newTestServer(t, testConfig()).handler(slog.Default())
+
+		rec := do(t, h, "GET", "/messages/?limit=5", "")
+		if rec.Code != http.StatusMovedPermanently {
+			t.Fatalf("GET /messages/ = %d, want 301", rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != "/messages?limit=5" {
+			t.Errorf("Location = %q, want /messages?limit=5", got)
+		}
+		if rec := do(t, h, "GET", "/messages//", ""); rec.Header().Get("Location") != "/messages" {
+			t.Errorf("GET /messages// redirects to %q, want /messages", rec.Header().Get("Location"))
+		}
+
+		// A redirect would make the client resend its body, so POST is
+		// served in place.
+		rec = do(t, h, "POST", "/echo/", `{"text":"slashed"}`)
+		if rec.Code != http.StatusOK || decode[Message](t, rec).Text != "slashed" {
+			t.Errorf("POST /echo/ = %d: %s, want the echo", rec.Code, rec.Body)
+		}
+		if rec := do(t, h, "GET", "/", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET / = %d, want the root served as is", rec.Code)
+		}
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.TrailingSlash = "rewrite"
+		h := newTestServer(t, cfg).handler(slog.Default())
+
+		if rec := do(t, h, "GET", "/messages/", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET /messages/ = %d, want 200 without a redirect", rec.Code)
+		}
+		if rec := do(t, h, "POST", "/echo/", `{"text":"slashed"}`); rec.Code != http.StatusOK {
+			t.Errorf("POST /echo/ = %d, want 200", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// throughput counts received messages and, from a background worker,
+// summarises the receive rate once per interval. Counting is a single
+// atomic add so the echo path never takes a lock.
+type throughput struct {
+	total    atomic.Int64
+	interval time.Duration
+	latest   atomic.Pointer[throughputWindow]
+}
+
+// throughputWindow is the rate measured over the most recent full interval.
+type throughputWindow struct {
+	start      time.Time
+	ratePerMin float64
+}
+
+func newThroughput(interval time.Duration) *throughput {
+	t := &throughput{interval: interval}
+	t.latest.Store(&throughputWindow{start: time.Now()})
+	return t
+}
+
+// record counts one received message.
+func (t *throughput) record() {
+	t.total.Add(1)
+}
+
+// run closes a window every interval, logging its rate, until ctx is
+// cancelled.
+func (t *throughput) run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	start, startTotal := time.Now(), t.total.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			total := t.total.Load()
+			w := &throughputWindow{
+				start:      start,
+				ratePerMin: float64(total-startTotal) / now.Sub(start).Minutes(),
+			}
+			t.latest.Store(w)
+			slog.Info("message throughput",
+				"messages_total", total,
+				"rate_per_min", w.ratePerMin,
+				"window_start", w.start,
+			)
+			start, startTotal = now, total
+		}
+	}
+}
+
+type statsResponse struct {
+	MessagesTotal int64     `json:"messages_total"`
+	RatePerMin    float64   `json:"rate_per_min"`
+	WindowStart   time.Time `json:"window_start"`
+}
+
+func (s *server) stats(w http.ResponseWriter, r *http.Request) {
+	latest := s.throughput.latest.Load()
+	writeJSON(w, http.StatusOK, statsResponse{
+		MessagesTotal: s.throughput.total.Load(),
+		RatePerMin:    latest.ratePerMin,
+		WindowStart:   latest.start.UTC(),
+	})
+}
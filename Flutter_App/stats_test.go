@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	cfg := testConfig()
+	cfg.StatsInterval = 50 * time.Millisecond
+	h := newTestServer(t, cfg).handler(slog.Default())
+	stats := func() statsResponse {
+		t.Helper()
+		rec := do(t, h, "GET", "/stats", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /stats = %d, want 200", rec.Code)
+		}
+		return decode[statsResponse](t, rec)
+	}
+
+	first := stats()
+	if first.MessagesTotal != 0 || first.RatePerMin != 0 {
+		t.Errorf("stats before any message = %+v, want zero", first)
+	}
+	// Wait for the worker to close a window, so it is counting before the
+	// messages arrive.
+	waitFor(t, "the first window to close", func() bool { return !stats().WindowStart.Equal(first.WindowStart) })
+	for _, text := range []string{"one", "two", "three"} {
+		postMessage(t, h, text)
+	}
+	if got := stats(); got.MessagesTotal != 3 {
+		t.Errorf("messages_total = %d, want 3 as soon as they are received", got.MessagesTotal)
+	}
+
+	// The rate only updates once the worker closes the window they fell in.
+	var got statsResponse
+	waitFor(t, "a window with a non-zero rate", func() bool {
+		got = stats()
+		return got.RatePerMin > 0
+	})
+	if got.MessagesTotal != 3 || got.WindowStart.IsZero() {
+		t.Errorf("stats = %+v, want 3 messages and a window start", got)
+	}
+}
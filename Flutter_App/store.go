@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by MessageStore.Get when no message has the
+// requested ID.
+var ErrNotFound = errors.New("message not found")
+
+// MessageStore persists received messages. Messages whose ExpiresAt has
+// passed are never returned, even before DeleteExpired removes them.
+type MessageStore interface {
+	// Save stores msg and returns the ID assigned to it.
+	Save(ctx context.Context, msg Message) (int64, error)
+	// Get returns the message with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id int64) (Message, error)
+	// List returns up to limit messages starting at offset, oldest first,
+	// along with the total number of messages that have not expired.
+	List(ctx context.Context, limit, offset int) ([]Message, int64, error)
+	// Search returns up to limit messages whose text contains query,
+	// ignoring case, starting at offset, oldest first, along with the total
+	// number of matches.
+	Search(ctx context.Context, query string, limit, offset int) ([]Message, int64, error)
+	// Count returns the number of stored messages. It is kept cheap for the
+	// X-Message-Count header, so it may include expired messages until
+	// DeleteExpired has run; List reports the exact total.
+	Count(ctx context.Context) (int64, error)
+	// Clear removes every stored message and returns how many were removed.
+	Clear(ctx context.Context) (int, error)
+	// DeleteExpired removes every message that has expired by now and
+	// returns how many were removed.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	// NextExpiry returns the earliest expiry time after now among the stored
+	// messages, or the zero time if none of them expires later.
+	NextExpiry(ctx context.Context, now time.Time) (time.Time, error)
+}
+
+// openStore returns the MessageStore selected by cfg.Storage.
+func openStore(ctx context.Context, cfg Config) (MessageStore, error) {
+	switch cfg.Storage {
+	case "memory":
+		return newMemoryStore(cfg.MaxStoredMessages), nil
+	case "sqlite":
+		return openSQLiteStore(ctx, cfg.SQLitePath, cfg.SQLiteAttempts)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+}
+
+// memoryStore keeps the most recent messages in memory. Once capacity is
+// reached the oldest message is evicted for each new one.
+type memoryStore struct {
+	mu       sync.RWMutex
+	messages []Message
+	nextID   int64
+	capacity int
+}
+
+func newMemoryStore(capacity int) *memoryStore {
+	return &memoryStore{capacity: capacity}
+}
+
+func (m *memoryStore) Save(ctx context.Context, msg Message) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	msg.ID = m.nextID
+
+	m.messages = append(m.messages, msg)
+	if len(m.messages) > m.capacity {
+		m.messages = m.messages[len(m.messages)-m.capacity:]
+	}
+	return msg.ID, nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, id int64) (Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// IDs are assigned in increasing order, so the slice is sorted by ID.
+	i := sort.Search(len(m.messages), func(i int) bool { return m.messages[i].ID >= id })
+	if i == len(m.messages) || m.messages[i].ID != id || m.messages[i].expired(time.Now()) {
+		return Message{}, ErrNotFound
+	}
+	return m.messages[i], nil
+}
+
+func (m *memoryStore) List(ctx context.Context, limit, offset int) ([]Message, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var total int64
+	out := []Message{}
+	for _, msg := range m.messages {
+		if msg.expired(now) {
+			continue
+		}
+		if total >= int64(offset) && len(out) < limit {
+			out = append(out, msg)
+		}
+		total++
+	}
+	return out, total, nil
+}
+
+func (m *memoryStore) Search(ctx context.Context, query string, limit, offset int) ([]Message, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	query = strings.ToLower(query)
+	var total int64
+	out := []Message{}
+	for _, msg := range m.messages {
+		if msg.expired(now) || !strings.Contains(strings.ToLower(msg.Text), query) {
+			continue
+		}
+		if total >= int64(offset) && len(out) < limit {
+			out = append(out, msg)
+		}
+		total++
+	}
+	return out, total, nil
+}
+
+func (m *memoryStore) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.messages)), nil
+}
+
+func (m *memoryStore) Clear(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.messages)
+	m.messages = nil
+	return n, nil
+}
+
+func (m *memoryStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	live := m.messages[:0]
+	for _, msg := range m.messages {
+		if !msg.expired(now) {
+			live = append(live, msg)
+		}
+	}
+	n := len(m.messages) - len(live)
+	clear(m.messages[len(live):])
+	m.messages = live
+	return n, nil
+}
+
+func (m *memoryStore) NextExpiry(ctx context.Context, now time.Time) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var next time.Time
+	for _, msg := range m.messages {
+		if msg.ExpiresAt.After(now) && (next.IsZero() || msg.ExpiresAt.Before(next)) {
+			next = msg.ExpiresAt
+		}
+	}
+	return next, nil
+}
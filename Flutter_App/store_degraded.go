@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// storeRetryInterval is how often a degraded server retries opening its
+// message store.
+const storeRetryInterval = 5 * time.Second
+
+// errStoreUnavailable is returned by every degradableStore call while no
+// backend is connected.
+var errStoreUnavailable = errors.New("message store unavailable")
+
+// degradableStore lets the server start, and keep serving everything that
+// does not need storage, when its backend cannot be opened. Store calls fail
+// with errStoreUnavailable until a backend is set.
+type degradableStore struct {
+	mu      sync.RWMutex
+	store   MessageStore
+	lastErr error
+}
+
+func (d *degradableStore) current() (MessageStore, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.store == nil {
+		return nil, errStoreUnavailable
+	}
+	return d.store, nil
+}
+
+// set connects the backend, leaving degraded mode.
+func (d *degradableStore) set(store MessageStore) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.store = store
+	d.lastErr = nil
+}
+
+// reconnect calls open every storeRetryInterval until it succeeds or ctx is
+// cancelled, then sets the store it returned.
+func (d *degradableStore) reconnect(ctx context.Context, open func(context.Context) (MessageStore, error)) {
+	ticker := time.NewTicker(storeRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			store, err := open(ctx)
+			if err != nil {
+				d.mu.Lock()
+				d.lastErr = err
+				d.mu.Unlock()
+				slog.Debug("message store still unavailable", "error", err)
+				continue
+			}
+			d.set(store)
+			slog.Info("message store connected, leaving degraded mode")
+			return
+		}
+	}
+}
+
+// check is a health check that reports degraded while no backend is set,
+// and otherwise runs the backend's own check if it has one.
+func (d *degradableStore) check(ctx context.Context) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.store != nil {
+		if c, ok := d.store.(interface{ check(context.Context) error }); ok {
+			return c.check(ctx)
+		}
+		return nil
+	}
+	if d.lastErr != nil {
+		return degradedError{fmt.Errorf("%w: %v", errStoreUnavailable, d.lastErr)}
+	}
+	return degradedError{errStoreUnavailable}
+}
+
+// Close closes the backend, if one is set and is an io.Closer.
+func (d *degradableStore) Close() error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if c, ok := d.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (d *degradableStore) Save(ctx context.Context, msg Message) (int64, error) {
+	s, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return s.Save(ctx, msg)
+}
+
+func (d *degradableStore) Get(ctx context.Context, id int64) (Message, error) {
+	s, err := d.current()
+	if err != nil {
+		return Message{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (d *degradableStore) List(ctx context.Context, limit, offset int) ([]Message, int64, error) {
+	s, err := d.current()
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.List(ctx, limit, offset)
+}
+
+func (d *degradableStore) Search(ctx context.Context, query string, limit, offset int) ([]Message, int64, error) {
+	s, err := d.current()
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.Search(ctx, query, limit, offset)
+}
+
+func (d *degradableStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return s.DeleteExpired(ctx, now)
+}
+
+func (d *degradableStore) NextExpiry(ctx context.Context, now time.Time) (time.Time, error) {
+	s, err := d.current()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.NextExpiry(ctx, now)
+}
+
+func (d *degradableStore) Count(ctx context.Context) (int64, error) {
+	s, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return s.Count(ctx)
+}
+
+func (d *degradableStore) Clear(ctx context.Context) (int, error) {
+	s, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return s.Clear(ctx)
+}
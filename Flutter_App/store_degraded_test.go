@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestDegradedStoreRecovers(t *testing.T) {
+	s := newTestServerWithStore(t, testConfig(), nil)
+	h := s.handler(slog.Default())
+
+	rec := do(t, h, "POST", "/echo", `{"text":"too early"}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("POST /echo while degraded = %d, want 503: %s", rec.Code, rec.Body)
+	}
+	if got := decode[errorResponse](t, rec); got.Error != "Storage unavailable" {
+		t.Errorf("error = %q, want Storage unavailable", got.Error)
+	}
+	if rec := do(t, h, "GET", "/messages", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /messages while degraded = %d, want 503", rec.Code)
+	}
+	// Routes that do not need storage keep working.
+	if rec := do(t, h, "GET", "/version", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /version while degraded = %d, want 200", rec.Code)
+	}
+	if got := decode[healthResponse](t, do(t, h, "GET", "/health", "")); got.Status != "degraded" {
+		t.Errorf("health = %q while degraded, want degraded", got.Status)
+	}
+
+	s.store.(*degradableStore).set(newMemoryStore(10))
+
+	if msg := postMessage(t, h, "recovered"); msg.ID != 1 {
+		t.Errorf("first message after recovery = %+v, want ID 1", msg)
+	}
+	if got := decode[healthResponse](t, do(t, h, "GET", "/health", "")); got.Status != "ok" {
+		t.Errorf("health = %q after recovery, want ok", got.Status)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteCheckTimeout bounds the health check so a hung disk fails the probe
+// instead of blocking it.
+const sqliteCheckTimeout = 2 * time.Second
+
+// migrations are applied in order at startup. The index of the last applied
+// migration is tracked in SQLite's user_version pragma, so new migrations
+// must only ever be appended.
+var migrations = []string{
+	`CREATE TABLE messages (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		text        TEXT NOT NULL,
+		received_at TEXT NOT NULL
+	)`,
+	// expires_at is in Unix nanoseconds, so it compares numerically; NULL
+	// means the message never expires.
+	`ALTER TABLE messages ADD COLUMN expires_at INTEGER`,
+	`CREATE INDEX messages_expires_at ON messages (expires_at)`,
+}
+
+// sqliteLive restricts a query to messages that have not expired. It takes
+// the current time in Unix nanoseconds as its parameter.
+const sqliteLive = "(expires_at IS NULL OR expires_at > ?)"
+
+// sqliteStore is a MessageStore backed by a SQLite database file. Writes
+// that fail because the database is busy are tried up to attempts times.
+//
+// Counts are always read from the database rather than cached, since with
+// ReusePort an old and a new process share the file during a restart and
+// each would miss the other's writes.
+type sqliteStore struct {
+	db       *sql.DB
+	attempts int
+}
+
+// openSQLiteStore opens (creating if needed) the database at path and brings
+// its schema up to date.
+func openSQLiteStore(ctx context.Context, path string, attempts int) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %s: %w", path, err)
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate sqlite %s: %w", path, err)
+	}
+	return &sqliteStore{db: db, attempts: attempts}, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Save(ctx context.Context, msg Message) (int64, error) {
+	var res sql.Result
+	err := withRetry(ctx, s.attempts, func() (err error) {
+		res, err = s.db.ExecContext(ctx,
+			"INSERT INTO messages (text, received_at, expires_at) VALUES (?, ?, ?)",
+			msg.Text, msg.ReceivedAt.UTC().Format(time.RFC3339Nano), nullableUnixNano(msg.ExpiresAt))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *sqliteStore) List(ctx context.Context, limit, offset int) ([]Message, int64, error) {
+	now := time.Now().UnixNano()
+
+	var total int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM messages WHERE "+sqliteLive, now).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, text, received_at, expires_at FROM messages WHERE "+sqliteLive+" ORDER BY id LIMIT ? OFFSET ?",
+		now, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, total, rows.Err()
+}
+
+// likeEscaper escapes the LIKE wildcards, and the escape character itself,
+// so a search query is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search uses LIKE, which SQLite only treats as case-insensitive for ASCII
+// letters.
+func (s *sqliteStore) Search(ctx context.Context, query string, limit, offset int) ([]Message, int64, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	now := time.Now().UnixNano()
+
+	var total int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM messages WHERE text LIKE ? ESCAPE '\' AND `+sqliteLive, pattern, now).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, text, received_at, expires_at FROM messages WHERE text LIKE ? ESCAPE '\' AND `+sqliteLive+` ORDER BY id LIMIT ? OFFSET ?`,
+		pattern, now, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, total, rows.Err()
+}
+
+func (s *sqliteStore) Get(ctx context.Context, id int64) (Message, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, text, received_at, expires_at FROM messages WHERE id = ? AND "+sqliteLive, id, time.Now().UnixNano())
+	msg, err := scanMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Message{}, ErrNotFound
+	}
+	return msg, err
+}
+
+// scanMessage reads an (id, text, received_at, expires_at) row.
+func scanMessage(row interface{ Scan(...any) error }) (Message, error) {
+	var msg Message
+	var receivedAt string
+	var expiresAt sql.NullInt64
+	if err := row.Scan(&msg.ID, &msg.Text, &receivedAt, &expiresAt); err != nil {
+		return Message{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, receivedAt)
+	if err != nil {
+		return Message{}, fmt.Errorf("message %d: bad received_at %q: %w", msg.ID, receivedAt, err)
+	}
+	msg.ReceivedAt = t
+	if expiresAt.Valid {
+		msg.ExpiresAt = time.Unix(0, expiresAt.Int64).UTC()
+	}
+	return msg, nil
+}
+
+// nullableUnixNano stores a zero time as NULL.
+func nullableUnixNano(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+func (s *sqliteStore) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages").Scan(&n)
+	return n, err
+}
+
+func (s *sqliteStore) Clear(ctx context.Context) (int, error) {
+	var res sql.Result
+	err := withRetry(ctx, s.attempts, func() (err error) {
+		res, err = s.db.ExecContext(ctx, "DELETE FROM messages")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *sqliteStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	var res sql.Result
+	err := withRetry(ctx, s.attempts, func() (err error) {
+		res, err = s.db.ExecContext(ctx,
+			"DELETE FROM messages WHERE expires_at <= ?", now.UnixNano())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *sqliteStore) NextExpiry(ctx context.Context, now time.Time) (time.Time, error) {
+	var next sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT MIN(expires_at) FROM messages WHERE expires_at > ?", now.UnixNano()).Scan(&next)
+	if err != nil || !next.Valid {
+		return time.Time{}, err
+	}
+	return time.Unix(0, next.Int64).UTC(), nil
+}
+
+// check confirms the database accepts writes, not just that it is open, by
+// inserting a row inside a transaction that is always rolled back.
+func (s *sqliteStore) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, sqliteCheckTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO messages (text, received_at) VALUES ('', '')"); err != nil {
+		return fmt.Errorf("sqlite: test write: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeBackends opens a fresh, empty store of each kind for every test.
+var storeBackends = map[string]func(t *testing.T) MessageStore{
+	"memory": func(t *testing.T) MessageStore {
+		return newMemoryStore(100)
+	},
+	"sqlite": func(t *testing.T) MessageStore {
+		s, err := openSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "messages.db"), 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	},
+}
+
+// forEachStore runs test against every store backend, so both keep the same
+// behaviour.
+func forEachStore(t *testing.T, test func(t *testing.T, s MessageStore)) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) { test(t, open(t)) })
+	}
+}
+
+// saveTexts saves a message for each text and returns their IDs.
+func saveTexts(t *testing.T, s MessageStore, texts ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, len(texts))
+	for i, text := range texts {
+		id, err := s.Save(context.Background(), newMessage(text, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestStoreSaveAndGet(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s MessageStore) {
+		ctx := context.Background()
+		ids := saveTexts(t, s, "one", "two")
+		if ids[0] != 1 || ids[1] != 2 {
+			t.Fatalf("ids = %v, want [1 2]", ids)
+		}
+
+		msg, err := s.Get(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != 2 || msg.Text != "two" || msg.ReceivedAt.IsZero() {
+			t.Errorf("Get(2) = %+v, want message two with its receive time", msg)
+		}
+
+		if _, err := s.Get(ctx, 3); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(3) error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreList(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s MessageStore) {
+		ctx := context.Background()
+		for i := range 5 {
+			saveTexts(t, s, fmt.Sprint("message ", i))
+		}
+
+		page, total, err := s.List(ctx, 2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Errorf("total = %d, want 5", total)
+		}
+		if len(page) != 2 || page[0].Text != "message 1" || page[1].Text != "message 2" {
+			t.Errorf("List(2, 1) = %+v, want messages 1 and 2", page)
+		}
+
+		page, _, err = s.List(ctx, 10, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page == nil || len(page) != 0 {
+			t.Errorf("List past the end = %#v, want an empty slice", page)
+		}
+	})
+}
+
+func TestStoreCountAndClear(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s MessageStore) {
+		ctx := context.Background()
+		saveTexts(t, s, "a", "b", "c")
+
+		if n, err := s.Count(ctx); err != nil || n != 3 {
+			t.Fatalf("Count = %d, %v, want 3", n, err)
+		}
+		if n, err := s.Clear(ctx); err != nil || n != 3 {
+			t.Fatalf("Clear = %d, %v, want 3", n, err)
+		}
+		if n, err := s.Count(ctx); err != nil || n != 0 {
+			t.Errorf("Count after Clear = %d, %v, want 0", n, err)
+		}
+		if page, total, err := s.List(ctx, 10, 0); err != nil || len(page) != 0 || total != 0 {
+			t.Errorf("List after Clear = %v, %d, %v, want nothing", page, total, err)
+		}
+	})
+}
+
+func TestStoreExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s MessageStore) {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		expiresSoon := now.Add(time.Hour)
+		for _, msg := range []Message{
+			{Text: "expired", ReceivedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Second)},
+			{Text: "expires soon", ReceivedAt: now, ExpiresAt: expiresSoon},
+			{Text: "expires later", ReceivedAt: now, ExpiresAt: now.Add(2 * time.Hour)},
+			{Text: "kept", ReceivedAt: now},
+		} {
+			if _, err := s.Save(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := s.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of an expired message = %v, want ErrNotFound", err)
+		}
+		page, total, err := s.List(ctx, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 3 || page[0].Text != "expires soon" || total != 3 {
+			t.Errorf("List = %+v (total %d), want the 3 live messages", page, total)
+		}
+		if !page[0].ExpiresAt.Equal(expiresSoon) || !page[2].ExpiresAt.IsZero() {
+			t.Errorf("expiry times = %s, %s, want %s and none", page[0].ExpiresAt, page[2].ExpiresAt, expiresSoon)
+		}
+		if found, _, _ := s.Search(ctx, "expired", 10, 0); len(found) != 0 {
+			t.Errorf("Search found expired messages: %+v", found)
+		}
+
+		next, err := s.NextExpiry(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !next.Equal(expiresSoon) {
+			t.Errorf("NextExpiry = %s, want %s", next, expiresSoon)
+		}
+		if next, _ := s.NextExpiry(ctx, now.Add(3*time.Hour)); !next.IsZero() {
+			t.Errorf("NextExpiry after every expiry = %s, want zero", next)
+		}
+
+		n, err := s.DeleteExpired(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, _ := s.Count(ctx); n != 1 || count != 3 {
+			t.Errorf("DeleteExpired removed %d leaving %d, want 1 removed and 3 left", n, count)
+		}
+		if n, _ := s.DeleteExpired(ctx, now.Add(90*time.Minute)); n != 1 {
+			t.Errorf("DeleteExpired later removed %d, want the message that expired since", n)
+		}
+	})
+}
+
+func TestStoreSearch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s MessageStore) {
+		ctx := context.Background()
+		saveTexts(t, s, "Hello world", "50% off", "file_name.txt", "filename.txt", "HELLO again", "goodbye")
+
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{"hello", []string{"Hello world", "HELLO again"}},
+			{"WORLD", []string{"Hello world"}},
+			{"%", []string{"50% off"}},
+			{"_", []string{"file_name.txt"}},
+			{"e_n", []string{"file_name.txt"}},
+			{"missing", nil},
+		}
+		for _, tt := range tests {
+			got, total, err := s.Search(ctx, tt.query, 10, 0)
+			if err != nil {
+				t.Fatalf("Search(%q): %v", tt.query, err)
+			}
+			texts := make([]string, len(got))
+			for i, msg := range got {
+				texts[i] = msg.Text
+			}
+			if !slices.Equal(texts, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("Search(%q) = %q (total %d), want %q", tt.query, texts, total, tt.want)
+			}
+		}
+
+		page, total, err := s.Search(ctx, "e", 2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].Text != "file_name.txt" || total != 5 {
+			t.Errorf("Search(\"e\", 2, 1) = %+v (total %d), want the second and third of 5 matches", page, total)
+		}
+	})
+}
+
+func TestSearchMessages(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+	postMessage(t, h, "needle")
+
+	rec := do(t, h, "GET", "/messages/search?q=haystack", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"messages":[]`) {
+		t.Errorf("body = %s, want an empty messages array rather than null", rec.Body)
+	}
+	if page := decode[messagePage](t, do(t, h, "GET", "/messages/search?q=NEED", "")); page.Total != 1 || page.Messages[0].Text != "needle" {
+		t.Errorf("search for NEED = %+v, want the needle", page)
+	}
+	if rec := do(t, h, "GET", "/messages/search", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("search without q = %d, want 400", rec.Code)
+	}
+}
+
+// stalledStore is a memory store whose reads and writes block until their
+// context is done, like a backend that has stopped responding.
+type stalledStore struct {
+	MessageStore
+}
+
+func (s stalledStore) Save(ctx context.Context, msg Message) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func (s stalledStore) List(ctx context.Context, limit, offset int) ([]Message, int64, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestStoreTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.StoreTimeout = 20 * time.Millisecond
+	h := newTestServerWithStore(t, cfg, stalledStore{newMemoryStore(10)}).handler(slog.Default())
+
+	for _, req := range []struct{ method, target, body string }{
+		{"POST", "/echo", `{"text":"hi"}`},
+		{"GET", "/messages", ""},
+	} {
+		start := time.Now()
+		rec := do(t, h, req.method, req.target, req.body)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s %s = %d, want 503: %s", req.method, req.target, rec.Code, rec.Body)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "Storage timed out" {
+			t.Errorf("%s %s error = %q, want a storage timeout", req.method, req.target, got.Error)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s %s took %s, want it cut off at the store timeout", req.method, req.target, elapsed)
+		}
+	}
+}
+
+func TestSQLiteCountsSeeOtherWriters(t *testing.T) {
+	// With ReusePort the old and new process share the database file for a
+	// while, so each must see the rows the other writes.
+	path := filepath.Join(t.TempDir(), "messages.db")
+	stores := make([]*sqliteStore, 2)
+	for i := range stores {
+		s, err := openSQLiteStore(t.Context(), path, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		stores[i] = s
+	}
+	old, current := stores[0], stores[1]
+
+	saveTexts(t, old, "from the old process")
+	saveTexts(t, current, "from the new process")
+	if n, err := current.Count(t.Context()); err != nil || n != 2 {
+		t.Errorf("Count = %d, %v, want both processes' messages", n, err)
+	}
+	if _, total, err := old.List(t.Context(), 10, 0); err != nil || total != 2 {
+		t.Errorf("List total = %d, %v, want both processes' messages", total, err)
+	}
+	if _, err := old.Clear(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := current.Count(t.Context()); err != nil || n != 0 {
+		t.Errorf("Count after the other process cleared = %d, %v, want 0", n, err)
+	}
+}
+
+func TestSQLiteCheckFailsWhenReadOnly(t *testing.T) {
+	store, err := openSQLiteStore(t.Context(), filepath.Join(t.TempDir(), "messages.db"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	h := newTestServerWithStore(t, testConfig(), store).handler(slog.Default())
+
+	if rec := do(t, h, "GET", "/health", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET /health on a writable database = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// Permissions do not stop root, so make the one pooled connection
+	// refuse writes instead.
+	store.db.SetMaxOpenConns(1)
+	if _, err := store.db.ExecContext(t.Context(), "PRAGMA query_only = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.check(t.Context()); err == nil {
+		t.Fatal("check passed on a read-only database")
+	}
+	rec := do(t, h, "GET", "/health", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /health on a read-only database = %d, want 503: %s", rec.Code, rec.Body)
+	}
+	if got := decode[healthResponse](t, rec); len(got.FailingChecks) != 1 || got.FailingChecks[0].Name != "storage" {
+		t.Errorf("failing_checks = %v, want the storage check", got.FailingChecks)
+	}
+}
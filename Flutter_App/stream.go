@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// flushWriter flushes after every write so each chunk copied by io.Copy
+// reaches the client straight away.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err == nil {
+		err = fw.rc.Flush()
+	}
+	return n, err
+}
+
+// echoStream copies the request body back to the client as it arrives,
+// without buffering, for payloads too large to echo as a Message. The body
+// is still capped at cfg.MaxBodyBytes; since the status has already been
+// sent by the time an over-long body is noticed, the response is aborted
+// rather than completed, so the client sees it truncated.
+func (s *server) echoStream(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > s.cfg.MaxBodyBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", s.cfg.MaxBodyBytes))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// HTTP/1.x otherwise stops reading the body once the response starts.
+	if err := rc.EnableFullDuplex(); err != nil {
+		slog.DebugContext(r.Context(), "enabling full duplex for echo stream", "error", err)
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// The status is left to the first write: writing it before the first
+	// read would skip the 100 Continue that clients sending
+	// "Expect: 100-continue" wait for.
+	w.Header().Set("Content-Type", contentType)
+
+	body := http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	n, err := io.Copy(flushWriter{w: w, rc: rc}, body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			slog.WarnContext(r.Context(), "echo stream exceeded body limit", "limit", tooLarge.Limit)
+		} else {
+			slog.DebugContext(r.Context(), "echo stream interrupted", "bytes", n, "error", err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+	slog.DebugContext(r.Context(), "echo stream finished", "bytes", n)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestEchoStream(t *testing.T) {
+	const size = 4 << 20
+	cfg := testConfig()
+	cfg.MaxBodyBytes = 2 * size
+	ts := serve(t, newTestServer(t, cfg))
+
+	payload := make([]byte, size)
+	rand.Read(payload)
+	// A pipe gives the request no Content-Length, so it goes out chunked
+	// and the server must copy it through as it arrives.
+	pr, pw := io.Pipe()
+	go func() {
+		for chunk := range slices.Chunk(payload, 64<<10) {
+			if _, err := pw.Write(chunk); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	res, err := http.Post(ts.URL+"/echo/stream", "application/octet-stream", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.StatusCode)
+	}
+	got, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("echoed %d bytes that differ from the %d sent", len(got), len(payload))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	timeout := WithTimeout(50 * time.Millisecond)
+
+	t.Run("fast handler", func(t *testing.T) {
+		h := timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", "fast")
+			writeJSON(w, http.StatusCreated, Message{Text: "in time"})
+		}))
+		rec := do(t, h, "GET", "/", "")
+		if rec.Code != http.StatusCreated || rec.Header().Get("X-Handler") != "fast" {
+			t.Fatalf("response = %d with X-Handler %q, want the handler's 201 and headers", rec.Code, rec.Header().Get("X-Handler"))
+		}
+		if got := decode[Message](t, rec); got.Text != "in time" {
+			t.Errorf("body = %+v, want the handler's", got)
+		}
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		responded := make(chan struct{})
+		lateWrite := make(chan error, 1)
+		h := timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			<-responded
+			_, err := w.Write([]byte("too late"))
+			lateWrite <- err
+		}))
+		rec := do(t, h, "GET", "/", "")
+		close(responded)
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status = %d, want 504", rec.Code)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "Request timed out" {
+			t.Errorf("error = %q, want Request timed out", got.Error)
+		}
+		if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("write after the deadline = %v, want ErrHandlerTimeout", err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// transforms maps the names accepted by POST /echo?transform= to the
+// function applied to the message text. Add an entry here to support a new
+// transform.
+var transforms = map[string]func(string) string{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"reverse": reverseRunes,
+}
+
+// lookupTransform returns the transform registered under name. An empty name
+// selects the identity transform.
+func lookupTransform(name string) (func(string) string, error) {
+	if name == "" {
+		return func(s string) string { return s }, nil
+	}
+	if t, ok := transforms[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q: must be one of %s", name, strings.Join(transformNames(), ", "))
+}
+
+// transformNames returns the registered transform names in sorted order.
+func transformNames() []string {
+	names := make([]string, 0, len(transforms))
+	for n := range transforms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reverseRunes reverses s by code point, so multi-byte characters survive.
+func reverseRunes(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+	return string(r)
+}
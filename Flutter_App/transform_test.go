@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestEchoTransform(t *testing.T) {
+	h := newTestServer(t, testConfig()).handler(slog.Default())
+
+	tests := []struct {
+		transform string
+		want      string
+	}{
+		{"", "Héllo, World"},
+		{"upper", "HÉLLO, WORLD"},
+		{"lower", "héllo, world"},
+		{"reverse", "dlroW ,olléH"},
+	}
+	for _, tt := range tests {
+		t.Run("transform="+tt.transform, func(t *testing.T) {
+			rec := do(t, h, "POST", "/echo?transform="+tt.transform, `{"text":"Héllo, World"}`)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if got := decode[Message](t, rec); got.Text != tt.want {
+				t.Errorf("text = %q, want %q", got.Text, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		rec := do(t, h, "POST", "/echo?transform=shout", `{"text":"hi"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+		}
+		want := `unknown transform "shout": must be one of lower, reverse, upper`
+		if got := decode[errorResponse](t, rec); got.Error != want {
+			t.Errorf("error = %q, want %q", got.Error, want)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// multipartOverhead is the slack allowed on top of the file size for the
+// multipart boundaries and part headers.
+const multipartOverhead = 64 << 10
+
+// uploadExtensions pins the extension used for common image types, since
+// mime.ExtensionsByType depends on the host's mime tables.
+var uploadExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type uploadResponse struct {
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// upload accepts a single file in the "file" field of a multipart form and
+// stores it in cfg.UploadDir under a generated name. The content type is
+// sniffed from the file itself rather than trusted from the client.
+func (s *server) upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.UploadMaxBytes+multipartOverhead)
+	if err := r.ParseMultipartForm(s.cfg.UploadMemoryBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("File exceeds %d bytes", s.cfg.UploadMaxBytes))
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "body must be multipart/form-data")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, `form must contain a "file" field`)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > s.cfg.UploadMaxBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("File exceeds %d bytes", s.cfg.UploadMaxBytes))
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		slog.ErrorContext(r.Context(), "reading upload", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error reading upload")
+		return
+	}
+	head = head[:n]
+	if n == 0 {
+		writeJSONError(w, http.StatusBadRequest, "file must not be empty")
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !slices.Contains(s.cfg.UploadAllowedTypes, contentType) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("file type %s is not allowed", contentType))
+		return
+	}
+
+	name, size, err := s.saveUpload(contentType, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "storing upload", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error storing upload")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "stored upload", "filename", name, "size", size, "content_type", contentType)
+	writeJSON(w, http.StatusCreated, uploadResponse{Filename: name, Size: size, ContentType: contentType})
+}
+
+// saveUpload writes src to a new file with a random name in the upload
+// directory, removing it again if the copy fails.
+func (s *server) saveUpload(contentType string, src io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(s.cfg.UploadDir, 0o750); err != nil {
+		return "", 0, err
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	name := hex.EncodeToString(b) + uploadExtension(contentType)
+	path := filepath.Join(s.cfg.UploadDir, name)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+	return name, size, nil
+}
+
+func uploadExtension(contentType string) string {
+	if ext, ok := uploadExtensions[contentType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// uploadRequest builds a multipart POST /upload carrying content as the
+// "file" field.
+func uploadRequest(t *testing.T, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestUpload(t *testing.T) {
+	cfg := testConfig()
+	cfg.UploadDir = t.TempDir()
+	h := newTestServer(t, cfg).handler(slog.Default())
+
+	t.Run("image", func(t *testing.T) {
+		var img bytes.Buffer
+		if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, uploadRequest(t, "pixel.gif", img.Bytes()))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+		}
+
+		got := decode[uploadResponse](t, rec)
+		// The type is sniffed, so the misleading .gif name is ignored.
+		if got.ContentType != "image/png" || !strings.HasSuffix(got.Filename, ".png") || got.Size != int64(img.Len()) {
+			t.Errorf("response = %+v, want a %d-byte image/png", got, img.Len())
+		}
+		stored, err := os.ReadFile(filepath.Join(cfg.UploadDir, got.Filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stored, img.Bytes()) {
+			t.Error("stored file differs from the upload")
+		}
+	})
+
+	t.Run("rejected type", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, uploadRequest(t, "notes.png", []byte("just some text\n")))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+		}
+		if got := decode[errorResponse](t, rec); got.Error != "file type text/plain is not allowed" {
+			t.Errorf("error = %q, want the sniffed type rejected", got.Error)
+		}
+	})
+
+	entries, err := os.ReadDir(cfg.UploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("upload dir holds %d files, want only the accepted image", len(entries))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// fieldError is one problem with one field of a request. Pointer is the
+// JSON pointer to the offending value; Index is set when the field belongs
+// to an item of a batch, in which case Pointer is relative to that item.
+type fieldError struct {
+	Index   *int   `json:"index,omitempty"`
+	Field   string `json:"field"`
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// validationError accumulates every field problem in a request so the client
+// can fix them all in one go, rather than one per round trip.
+type validationError struct {
+	Errors []fieldError
+}
+
+// add records a problem with field.
+func (v *validationError) add(field, format string, args ...any) {
+	v.Errors = append(v.Errors, fieldError{Field: field, Pointer: "/" + field, Message: fmt.Sprintf(format, args...)})
+}
+
+// addItem records every problem in item as belonging to the batch item at
+// index.
+func (v *validationError) addItem(index int, item *validationError) {
+	for _, fe := range item.Errors {
+		fe.Index = &index
+		v.Errors = append(v.Errors, fe)
+	}
+}
+
+// err returns v as an error, or nil if nothing was recorded.
+func (v *validationError) err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v *validationError) Error() string {
+	msgs := make([]string, len(v.Errors))
+	for i, fe := range v.Errors {
+		msgs[i] = fe.Field + " " + fe.Message
+		if fe.Index != nil {
+			msgs[i] = fmt.Sprintf("item %d: %s", *fe.Index, msgs[i])
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type validationResponse struct {
+	Error  string       `json:"error"`
+	Status int          `json:"status"`
+	Errors []fieldError `json:"errors"`
+}
+
+// writeValidationError reports v with 422, keeping the error and status
+// fields every other error response has.
+func writeValidationError(w http.ResponseWriter, v *validationError) {
+	writeJSON(w, http.StatusUnprocessableEntity, validationResponse{
+		Error:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Errors: v.Errors,
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X main.gitCommit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	gitCommit = "dev"
+	buildTime = "dev"
+)
+
+type versionResponse struct {
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, versionResponse{
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	})
+}
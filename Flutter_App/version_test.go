@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	rec := do(t, http.HandlerFunc(version), "GET", "/version", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	got := decode[map[string]string](t, rec)
+	for _, key := range []string{"git_commit", "build_time", "go_version"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("response has no %q key: %v", key, got)
+		}
+	}
+	// Without -ldflags the build metadata keeps its defaults.
+	if got["git_commit"] != "dev" || got["build_time"] != "dev" || got["go_version"] != runtime.Version() {
+		t.Errorf("response = %v, want dev build metadata and %s", got, runtime.Version())
+	}
+}